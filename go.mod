@@ -4,6 +4,7 @@ go 1.16
 
 require (
 	github.com/go-sql-driver/mysql v1.6.0
+	github.com/mattn/go-sqlite3 v1.14.22
 	github.com/onsi/ginkgo v1.16.5
 	github.com/onsi/gomega v1.20.1
 )
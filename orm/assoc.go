@@ -0,0 +1,334 @@
+package orm
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+/*
+ * 关联成员通过"orm"描述符声明，不设置"db"描述符，查询时不对应列；使用 Preload 或 QueryOpts.SetPreload
+ * 加载关联数据，每个关联只执行一次 IN (...) 查询，避免逐行查询（N+1）
+ *  has_one      一对一，关联表的 foreign_key 列引用本表的 references 列，成员类型为 *T 或 T；
+ *               多行匹配时取关联表主键最小的一行，关联表没有主键时返回错误
+ *  has_many     一对多，关联表的 foreign_key 列引用本表的 references 列，成员类型为 []*T 或 []T，按关联表主键排序
+ *  belongs_to   多对一，本表的 foreign_key 列引用关联表的 references 列，成员类型为 *T 或 T
+ *
+ *  foreign_key:C  外键列名，必须设置
+ *  references:C   被引用的列名，默认为被引用表的主键
+ *
+ *  e.g
+ *  type User struct {
+ *      ID      int64    `db:"id" orm:"pk;auto_increment"`
+ *      Name    string   `db:"name"`
+ *      Orders  []*Order `orm:"has_many;foreign_key:user_id"`
+ *  }
+ *
+ *  type Order struct {
+ *      ID      int64   `db:"id" orm:"pk;auto_increment"`
+ *      UserID  int64   `db:"user_id"`
+ *      User    *User   `orm:"belongs_to;foreign_key:user_id"`
+ *  }
+ *
+ *  opts := orm.QueryOpts{}
+ *  opts.SetPreload("Orders")
+ *  records, err := orm.QueryWith(ctx, db, opts, "SELECT * FROM user", &User{})
+ */
+
+const (
+	hasOne    = "has_one"
+	hasMany   = "has_many"
+	belongsTo = "belongs_to"
+)
+
+type assocInfo struct {
+	index      int          // 成员下标
+	name       string       // 成员名
+	kind       string       // has_one / has_many / belongs_to
+	foreignKey string       // 外键列名
+	references string       // 被引用的列名，为空时使用主键
+	elemType   reflect.Type // 关联的结构体类型
+	slice      bool         // 成员是否为切片
+	ptr        bool         // 成员（或切片元素）是否为指针
+}
+
+// isAssociation 判断成员是否为关联成员
+func isAssociation(sf reflect.StructField) bool {
+	for _, opt := range strings.Split(sf.Tag.Get(ormTagName), ";") {
+		switch strings.ToLower(strings.TrimSpace(opt)) {
+		case hasOne, hasMany, belongsTo:
+			return true
+		}
+	}
+
+	return false
+}
+
+func parseAssoc(index int, sf reflect.StructField) (*assocInfo, error) {
+	a := &assocInfo{index: index, name: sf.Name}
+	for _, opt := range strings.Split(sf.Tag.Get(ormTagName), ";") {
+		opt = strings.TrimSpace(opt)
+		if opt == "" {
+			continue
+		}
+
+		key, value := opt, ""
+		if i := strings.Index(opt, ":"); i >= 0 {
+			key, value = strings.TrimSpace(opt[:i]), strings.TrimSpace(opt[i+1:])
+		}
+
+		switch strings.ToLower(key) {
+		case hasOne, hasMany, belongsTo:
+			a.kind = strings.ToLower(key)
+		case "foreign_key":
+			a.foreignKey = value
+		case "references":
+			a.references = value
+		default:
+			return nil, fmt.Errorf("unknown association option %q", key)
+		}
+	}
+
+	if a.foreignKey == "" {
+		return nil, errors.New("foreign_key required")
+	}
+
+	t := sf.Type
+	if a.kind == hasMany {
+		if t.Kind() != reflect.Slice {
+			return nil, errors.New("has_many needs a slice")
+		}
+		a.slice = true
+		t = t.Elem()
+	}
+
+	if t.Kind() == reflect.Ptr {
+		a.ptr = true
+		t = t.Elem()
+	}
+
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%s needs a struct", a.kind)
+	}
+
+	a.elemType = t
+	return a, nil
+}
+
+// assoc 根据成员名查找关联
+func (m *modelInfo) assoc(name string) *assocInfo {
+	for _, a := range m.assocs {
+		if a.name == name {
+			return a
+		}
+	}
+
+	return nil
+}
+
+// keyColumn 返回被引用的列，未设置 references 时为唯一主键
+func (m *modelInfo) keyColumn(references string) (*fieldInfo, error) {
+	if references != "" {
+		if f := m.field(references); f != nil {
+			return f, nil
+		}
+		return nil, fmt.Errorf("column %s not found in %s", references, m.table)
+	}
+
+	if pks := m.pk(); len(pks) == 1 {
+		return pks[0], nil
+	}
+
+	return nil, fmt.Errorf("%s needs a single primary key or references", m.table)
+}
+
+// Preload 加载关联成员，records为 Query 返回的同一类型的记录（数据对象指针），fields为成员名，
+// 嵌套关联以 . 分隔，如 "Orders.Items"；关联表的数据对象包含 deleted_at 列时，已软删除的行不会被加载
+func Preload(ctx context.Context, db Executor, records []interface{}, fields ...string) error {
+	if len(records) == 0 || len(fields) == 0 {
+		return nil
+	}
+
+	m, err := parseModel(records[0])
+	if err != nil {
+		return err
+	}
+
+	// 按第一级成员分组，嵌套部分在加载关联记录后递归处理
+	var names []string
+	nested := make(map[string][]string)
+	for _, field := range fields {
+		name, rest := field, ""
+		if i := strings.Index(field, "."); i >= 0 {
+			name, rest = field[:i], field[i+1:]
+		}
+
+		if _, ok := nested[name]; !ok {
+			names = append(names, name)
+			nested[name] = nil
+		}
+
+		if rest != "" {
+			nested[name] = append(nested[name], rest)
+		}
+	}
+
+	for _, name := range names {
+		a := m.assoc(name)
+		if a == nil {
+			return fmt.Errorf("association %s not found in %s", name, m.typ.Name())
+		}
+
+		if err := preload(ctx, db, m, a, records, nested[name]); err != nil {
+			return fmt.Errorf("preload %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+func preload(ctx context.Context, db Executor, m *modelInfo, a *assocInfo, records []interface{}, nested []string) error {
+	related, err := parseModel(reflect.New(a.elemType).Interface())
+	if err != nil {
+		return err
+	}
+
+	// parentKey 为本表中用于匹配的列，childKey 为关联表中用于匹配的列
+	var parentKey, childKey *fieldInfo
+	if a.kind == belongsTo {
+		if parentKey = m.field(a.foreignKey); parentKey == nil {
+			return fmt.Errorf("column %s not found in %s", a.foreignKey, m.table)
+		}
+		if childKey, err = related.keyColumn(a.references); err != nil {
+			return err
+		}
+	} else {
+		if parentKey, err = m.keyColumn(a.references); err != nil {
+			return err
+		}
+		if childKey = related.field(a.foreignKey); childKey == nil {
+			return fmt.Errorf("column %s not found in %s", a.foreignKey, related.table)
+		}
+	}
+
+	var keys []interface{}
+	seen := make(map[string]bool)
+	for _, record := range records {
+		value, key, ok := keyOf(reflect.ValueOf(record).Elem().Field(parentKey.index))
+		if ok && !seen[key] {
+			seen[key] = true
+			keys = append(keys, value)
+		}
+	}
+
+	var pkCols []string
+	for _, f := range related.pk() {
+		pkCols = append(pkCols, f.column)
+	}
+
+	groups := make(map[string][]interface{})
+	for start := 0; start < len(keys); start += defaultChunkSize {
+		end := start + defaultChunkSize
+		if end > len(keys) {
+			end = len(keys)
+		}
+
+		b, err := SelectModel(reflect.New(a.elemType).Interface(), defaultTagName)
+		if err != nil {
+			return err
+		}
+
+		// 按主键排序，保证 has_one 取到的行及 has_many 的顺序稳定
+		b = b.From(related.table).Where(childKey.column+" IN (?)", keys[start:end])
+		if len(pkCols) > 0 {
+			b = b.OrderBy(pkCols...)
+		}
+
+		script, args := b.ToSQL()
+		children, err := Query(ctx, db, script, reflect.New(a.elemType).Interface(), args...)
+		if err != nil {
+			return err
+		}
+
+		if err := Preload(ctx, db, children, nested...); err != nil {
+			return err
+		}
+
+		for _, child := range children {
+			if _, key, ok := keyOf(reflect.ValueOf(child).Elem().Field(childKey.index)); ok {
+				groups[key] = append(groups[key], child)
+			}
+		}
+	}
+
+	for _, record := range records {
+		v := reflect.ValueOf(record).Elem()
+		field := v.Field(a.index)
+
+		_, key, ok := keyOf(v.Field(parentKey.index))
+		children := groups[key]
+		if !ok {
+			children = nil
+		}
+
+		if a.slice {
+			s := reflect.MakeSlice(field.Type(), 0, len(children))
+			for _, child := range children {
+				s = reflect.Append(s, assocValue(child, a.ptr))
+			}
+			field.Set(s)
+			continue
+		}
+
+		if len(children) == 0 {
+			field.Set(reflect.Zero(field.Type()))
+			continue
+		}
+
+		if len(children) > 1 && len(pkCols) == 0 {
+			return fmt.Errorf("%d rows in %s match %s %s, need a primary key to pick one", len(children), related.table, childKey.column, key)
+		}
+
+		field.Set(assocValue(children[0], a.ptr))
+	}
+
+	return nil
+}
+
+func assocValue(record interface{}, ptr bool) reflect.Value {
+	if ptr {
+		return reflect.ValueOf(record)
+	}
+
+	return reflect.ValueOf(record).Elem()
+}
+
+// keyOf 返回关联列的值及用于匹配的键，值为空（nil 指针或无效的 sql.NullXXX）时ok为false；
+// 以字符串作为键，避免 int 与 int64 等类型不同导致无法匹配
+func keyOf(v reflect.Value) (interface{}, string, bool) {
+	value := v.Interface()
+	if valuer, ok := value.(driver.Valuer); ok {
+		var err error
+		if value, err = valuer.Value(); err != nil {
+			return nil, "", false
+		}
+	} else if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil, "", false
+		}
+		value = v.Elem().Interface()
+	}
+
+	if value == nil {
+		return nil, "", false
+	}
+
+	if b, ok := value.([]byte); ok {
+		value = string(b)
+	}
+
+	return value, fmt.Sprint(value), true
+}
@@ -0,0 +1,126 @@
+package orm_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"util/orm"
+	"util/orm/ormtest"
+)
+
+type Customer struct {
+	ID       int64   `db:"id" orm:"pk;auto_increment"`
+	UserID   int     `db:"user_id"`
+	UserName string  `db:"user_name"`
+	City     string  `db:"city"`
+	Bills    []*Bill `orm:"has_many;foreign_key:user_id;references:user_id"`
+	Latest   *Bill   `orm:"has_one;foreign_key:user_id;references:user_id"`
+}
+
+func (c *Customer) TableName() string {
+	return "sample"
+}
+
+type Bill struct {
+	ID       int64     `db:"id" orm:"pk;auto_increment"`
+	UserID   int       `db:"user_id"`
+	Amount   int       `db:"amount"`
+	Customer *Customer `orm:"belongs_to;foreign_key:user_id;references:user_id"`
+}
+
+var _ = Describe("Association", func() {
+
+	BeforeEach(requireMySQL)
+
+	BeforeEach(func() {
+		script, err := orm.CreateTableSQL(&Bill{}, orm.MySQL)
+		Expect(err).Should(Succeed())
+
+		for _, stmt := range orm.SplitStatements(script) {
+			_, err = db.Exec(stmt)
+			Expect(err).Should(Succeed())
+		}
+
+		_, err = db.Exec("INSERT INTO bill (user_id, amount) VALUES (1000, 10), (1000, 20), (1001, 30)")
+		Expect(err).Should(Succeed())
+	})
+
+	AfterEach(func() {
+		_, err := db.Exec("DROP TABLE bill")
+		Expect(err).Should(Succeed())
+	})
+
+	Context("preload", func() {
+		It("should stitch has-many and has-one", func() {
+			opts := orm.QueryOpts{}
+			opts.SetPreload("Bills", "Latest")
+
+			records, err := orm.QueryWith(context.TODO(), db, opts, "SELECT * FROM sample WHERE user_id IN (1000, 1001, 1002) ORDER BY user_id", &Customer{})
+			Expect(err).Should(Succeed())
+			Expect(len(records) == 3).Should(BeTrue())
+
+			c0, c1, c2 := records[0].(*Customer), records[1].(*Customer), records[2].(*Customer)
+			Expect(len(c0.Bills) == 2 && len(c1.Bills) == 1 && len(c2.Bills) == 0).Should(BeTrue())
+			Expect(c1.Latest != nil && c1.Latest.Amount == 30).Should(BeTrue())
+			Expect(c2.Latest == nil).Should(BeTrue())
+		})
+
+		It("should load belongs-to and nested associations", func() {
+			opts := orm.QueryOpts{}
+			opts.SetPreload("Customer.Bills")
+
+			records, err := orm.QueryWith(context.TODO(), db, opts, "SELECT * FROM bill ORDER BY id", &Bill{})
+			Expect(err).Should(Succeed())
+			Expect(len(records) == 3).Should(BeTrue())
+
+			bill := records[2].(*Bill)
+			Expect(bill.Customer != nil && bill.Customer.UserName == "user_1").Should(BeTrue())
+			Expect(len(records[0].(*Bill).Customer.Bills) == 2).Should(BeTrue())
+		})
+
+		It("should reject unknown association", func() {
+			records, err := orm.Query(context.TODO(), db, "SELECT * FROM bill", &Bill{})
+			Expect(err).Should(Succeed())
+			Expect(orm.Preload(context.TODO(), db, records, "Items")).ShouldNot(Succeed())
+		})
+	})
+})
+
+type Memo struct {
+	UserID int    `db:"user_id"`
+	Text   string `db:"text"`
+}
+
+type MemoOwner struct {
+	ID     int64 `db:"id" orm:"pk"`
+	UserID int   `db:"user_id"`
+	Memo   *Memo `orm:"has_one;foreign_key:user_id;references:user_id"`
+}
+
+var _ = Describe("Association without database", func() {
+	It("should pick has-one by primary key", func() {
+		mockDB, mock := ormtest.New()
+		defer mockDB.Close()
+
+		mock.ExpectQuery("FROM bill WHERE user_id IN (?) ORDER BY id").WithArgs(1000).
+			WillReturnRows(ormtest.NewRows("id", "user_id", "amount").AddRow(1, 1000, 10).AddRow(2, 1000, 20))
+
+		customer := &Customer{ID: 1, UserID: 1000}
+		Expect(orm.Preload(context.TODO(), mockDB, []interface{}{customer}, "Latest")).Should(Succeed())
+		Expect(customer.Latest.ID == 1 && customer.Latest.Amount == 10).Should(BeTrue())
+		Expect(mock.ExpectationsWereMet()).Should(Succeed())
+	})
+
+	It("should reject ambiguous has-one without primary key", func() {
+		mockDB, mock := ormtest.New()
+		defer mockDB.Close()
+
+		mock.ExpectQuery("FROM memo WHERE user_id IN (?)").WithArgs(1000).
+			WillReturnRows(ormtest.NewRows("user_id", "text").AddRow(1000, "a").AddRow(1000, "b"))
+
+		owner := &MemoOwner{ID: 1, UserID: 1000}
+		Expect(orm.Preload(context.TODO(), mockDB, []interface{}{owner}, "Memo")).ShouldNot(Succeed())
+		Expect(mock.ExpectationsWereMet()).Should(Succeed())
+	})
+})
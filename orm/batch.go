@@ -0,0 +1,183 @@
+package orm
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+/*
+ * BatchInsert 将数据对象按块拼装成多行 INSERT 语句批量写入，所有块在同一个事务中执行，任意一块失败即停止并回滚；
+ * db为 *sql.Tx 时在保存点中执行；块中出现死锁时按 WithTx 的策略重试整个事务
+ */
+
+const (
+	defaultTagName   = "db"
+	defaultChunkSize = 500
+)
+
+// BatchOpts 批量写入选项
+type BatchOpts struct {
+	dialect *Dialect
+}
+
+// SetDialect 设置数据库方言，决定占位符的书写方式及数量上限，默认为 MySQL
+func (opts *BatchOpts) SetDialect(dialect Dialect) {
+	opts.dialect = &dialect
+}
+
+// ChunkError 记录某一块数据写入失败的信息，[Start, End) 为该块在原切片中的下标范围
+type ChunkError struct {
+	Chunk int
+	Start int
+	End   int
+	Err   error
+}
+
+func (e *ChunkError) Error() string {
+	return fmt.Sprintf("chunk %d [%d, %d): %v", e.Chunk, e.Start, e.End, e.Err)
+}
+
+func (e *ChunkError) Unwrap() error {
+	return e.Err
+}
+
+// BatchInsertError 批量写入失败时返回，Chunk为第一个失败的块，之后的块不再执行；出现该错误时事务已回滚
+type BatchInsertError struct {
+	Chunk *ChunkError
+}
+
+func (e *BatchInsertError) Error() string {
+	return "batch insert failed in " + e.Chunk.Error()
+}
+
+func (e *BatchInsertError) Unwrap() error {
+	return e.Chunk
+}
+
+// BatchInsert 批量写入数据，models为数据对象切片（元素为结构体或结构体指针），列名取自"db"描述符，
+// 写入前按约定填充 created_at、updated_at 及 version；
+// chunkSize指定每条语句写入的行数，0表示使用默认值，实际行数不会超过方言的占位符数量上限；返回写入的总行数
+func BatchInsert(ctx context.Context, db Executor, table string, models interface{}, chunkSize int, opts ...BatchOpts) (int64, error) {
+	value := reflect.ValueOf(models)
+	if value.Kind() != reflect.Slice {
+		return 0, errors.New("need a slice")
+	}
+
+	if value.Len() == 0 {
+		return 0, nil
+	}
+
+	elemType := value.Type().Elem()
+	if elemType.Kind() == reflect.Ptr {
+		elemType = elemType.Elem()
+	}
+
+	if elemType.Kind() != reflect.Struct {
+		return 0, errors.New("need a slice of struct")
+	}
+
+	for i := 0; i < value.Len(); i++ {
+		if value.Index(i).Kind() == reflect.Ptr && value.Index(i).IsNil() {
+			return 0, fmt.Errorf("nil element at index %d", i)
+		}
+	}
+
+	cols, err := GetColNames(reflect.New(elemType).Interface(), defaultTagName)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(cols) == 0 {
+		return 0, errors.New("no columns found")
+	}
+
+	if chunkSize <= 0 {
+		chunkSize = defaultChunkSize
+	}
+
+	dialect := MySQL
+	if len(opts) > 0 && opts[0].dialect != nil {
+		dialect = *opts[0].dialect
+	}
+
+	limit := dialect.MaxPlaceholders() / len(cols)
+	if limit < 1 {
+		return 0, fmt.Errorf("%d columns exceed the placeholder limit %d of %s", len(cols), dialect.MaxPlaceholders(), dialect)
+	}
+
+	if chunkSize > limit {
+		chunkSize = limit
+	}
+
+	// 填充 created_at、updated_at 及 version
+	m, err := parseModel(reflect.New(elemType).Interface())
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	for i := 0; i < value.Len(); i++ {
+		m.fillInsert(reflect.Indirect(value.Index(i)), now)
+	}
+
+	var total int64
+	err = WithTx(ctx, db, nil, func(tx *sql.Tx) error {
+		total = 0
+
+		for start, chunk := 0, 0; start < value.Len(); start, chunk = start+chunkSize, chunk+1 {
+			end := start + chunkSize
+			if end > value.Len() {
+				end = value.Len()
+			}
+
+			script, args := buildInsert(table, cols, value.Slice(start, end))
+			result, err := tx.ExecContext(ctx, dialect.Rebind(script), args...)
+			if err != nil {
+				return &BatchInsertError{Chunk: &ChunkError{Chunk: chunk, Start: start, End: end, Err: err}}
+			}
+
+			affected, _ := result.RowsAffected()
+			total += affected
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	notifyExec(db, table)
+	return total, nil
+}
+
+// buildInsert 构建多行写入语句，rows为结构体（或结构体指针）切片
+func buildInsert(table string, cols []string, rows reflect.Value) (string, []interface{}) {
+	placeholder := "(" + strings.TrimSuffix(strings.Repeat("?,", len(cols)), ",") + ")"
+
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("INSERT INTO %s (%s) VALUES ", table, strings.Join(cols, ", ")))
+
+	args := make([]interface{}, 0, rows.Len()*len(cols))
+	for i := 0; i < rows.Len(); i++ {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		b.WriteString(placeholder)
+
+		row := reflect.Indirect(rows.Index(i))
+		for j := 0; j < row.NumField(); j++ {
+			if isAssociation(row.Type().Field(j)) {
+				continue
+			}
+			args = append(args, row.Field(j).Interface())
+		}
+	}
+
+	return b.String(), args
+}
@@ -0,0 +1,119 @@
+package orm_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"util/orm"
+	"util/orm/ormtest"
+)
+
+var _ = Describe("Batch", func() {
+
+	BeforeEach(requireMySQL)
+
+	Context("batch insert", func() {
+		It("should be succeed", func() {
+			var users []*UserInfo
+			for i := 0; i < 25; i++ {
+				users = append(users, &UserInfo{UserID: 5000 + i, UserName: fmt.Sprintf("batch_%d", i), City: "hangzhou"})
+			}
+
+			n, err := orm.BatchInsert(context.TODO(), db, "sample", users, 10)
+			Expect(err).Should(Succeed())
+			Expect(n == 25).Should(BeTrue())
+
+			cols, err := orm.GetColNames(&UserInfo{}, "db")
+			Expect(err).Should(Succeed())
+
+			sql := fmt.Sprintf("SELECT %s FROM sample WHERE city = 'hangzhou'", strings.Join(cols, ","))
+			records, err := orm.Query(context.TODO(), db, sql, &UserInfo{})
+			Expect(err).Should(Succeed())
+			Expect(len(records) == 25).Should(BeTrue())
+		})
+
+		It("should rollback all chunks on error", func() {
+			users := []UserInfo{{UserID: 6000, UserName: "ok", City: "xian"}}
+			_, err := orm.BatchInsert(context.TODO(), db, "not_exist_table", users, 10)
+			Expect(err).ShouldNot(Succeed())
+
+			var batchErr *orm.BatchInsertError
+			Expect(errors.As(err, &batchErr)).Should(BeTrue())
+			Expect(batchErr.Chunk.Start == 0 && batchErr.Chunk.End == 1).Should(BeTrue())
+		})
+
+		It("should reject non slice", func() {
+			_, err := orm.BatchInsert(context.TODO(), db, "sample", &UserInfo{}, 10)
+			Expect(err).ShouldNot(Succeed())
+		})
+	})
+})
+
+var _ = Describe("Batch without database", func() {
+	var users []UserInfo
+	for i := 0; i < 700; i++ {
+		users = append(users, UserInfo{UserID: 5000 + i, UserName: fmt.Sprintf("batch_%d", i), City: "hangzhou"})
+	}
+
+	It("should stop at first failed chunk", func() {
+		mockDB, mock := ormtest.New()
+		defer mockDB.Close()
+
+		failed := errors.New("failed")
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT INTO sample").WillReturnResult(0, 333)
+		mock.ExpectExec("INSERT INTO sample").WillReturnError(failed)
+		mock.ExpectRollback()
+
+		// SQLite 占位符上限999，每块最多333行
+		opts := orm.BatchOpts{}
+		opts.SetDialect(orm.SQLite)
+		_, err := orm.BatchInsert(context.TODO(), mockDB, "sample", users, 0, opts)
+
+		var chunkErr *orm.ChunkError
+		Expect(errors.As(err, &chunkErr)).Should(BeTrue())
+		Expect(chunkErr.Chunk == 1 && chunkErr.Start == 333 && chunkErr.End == 666).Should(BeTrue())
+		Expect(errors.Is(err, failed)).Should(BeTrue())
+		Expect(mock.ExpectationsWereMet()).Should(Succeed())
+	})
+
+	It("should retry on deadlock in chunk", func() {
+		mockDB, mock := ormtest.New()
+		defer mockDB.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT INTO sample").WillReturnError(&mysql.MySQLError{Number: 1213, Message: "Deadlock found"})
+		mock.ExpectRollback()
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT INTO sample").WillReturnResult(0, 1)
+		mock.ExpectCommit()
+
+		n, err := orm.BatchInsert(context.TODO(), mockDB, "sample", users[:1], 0)
+		Expect(err).Should(Succeed())
+		Expect(n == 1).Should(BeTrue())
+		Expect(mock.ExpectationsWereMet()).Should(Succeed())
+	})
+
+	It("should reject columns beyond placeholder limit", func() {
+		mockDB, mock := ormtest.New()
+		defer mockDB.Close()
+
+		var fields []reflect.StructField
+		for i := 0; i < 1000; i++ {
+			fields = append(fields, reflect.StructField{Name: fmt.Sprintf("C%d", i), Type: reflect.TypeOf(0), Tag: reflect.StructTag(fmt.Sprintf(`db:"c%d"`, i))})
+		}
+		models := reflect.MakeSlice(reflect.SliceOf(reflect.StructOf(fields)), 1, 1)
+
+		opts := orm.BatchOpts{}
+		opts.SetDialect(orm.SQLite)
+		_, err := orm.BatchInsert(context.TODO(), mockDB, "wide", models.Interface(), 0, opts)
+		Expect(err != nil && strings.Contains(err.Error(), "placeholder limit")).Should(BeTrue())
+		Expect(mock.ExpectationsWereMet()).Should(Succeed())
+	})
+})
@@ -0,0 +1,287 @@
+package orm
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+/*
+ * SelectBuilder 链式构建查询语句，条件中统一使用 ? 作为占位符，ToSQL 时按方言转换
+ *  e.g
+ *  sql, args := orm.Select("user_id", "user_name").From("sample").Where("city = ?", "beijing").
+ *      OrderBy("user_id DESC").Limit(10).ToSQL()
+ *  records, err := orm.Query(ctx, db, sql, &UserInfo{}, args...)
+ */
+
+// SelectBuilder 查询语句构建器
+type SelectBuilder struct {
+	dialect Dialect
+	cols    []string
+	table   string
+	joins   []string
+	wheres  []string
+	groups  []string
+	orders  []string
+	limit   int
+	offset  int
+
+	softDelete string // 软删除过滤条件，由 SelectModel 根据 deleted_at 列设置
+
+	joinArgs  []interface{}
+	whereArgs []interface{}
+}
+
+// Select 创建查询构建器，cols为空时查询所有列
+func Select(cols ...string) *SelectBuilder {
+	return &SelectBuilder{
+		dialect: MySQL,
+		cols:    cols,
+		limit:   -1,
+		offset:  -1,
+	}
+}
+
+// SelectModel 创建查询构建器，列名取自modelPtr的tagName描述符，列顺序与结构体成员顺序一致，可直接用于Query；
+// 数据对象包含 deleted_at 列时自动过滤已软删除的行，可以通过 Unscoped 取消
+func SelectModel(modelPtr interface{}, tagName string) (*SelectBuilder, error) {
+	cols, err := GetColNames(modelPtr, tagName)
+	if err != nil {
+		return nil, err
+	}
+
+	b := Select(cols...)
+	for _, col := range cols {
+		if col == ColDeletedAt {
+			b.softDelete = ColDeletedAt + " IS NULL"
+		}
+	}
+
+	return b, nil
+}
+
+// Unscoped 查询结果包含已软删除的行
+func (b *SelectBuilder) Unscoped() *SelectBuilder {
+	b.softDelete = ""
+	return b
+}
+
+// WithDialect 设置方言，默认为MySQL
+func (b *SelectBuilder) WithDialect(d Dialect) *SelectBuilder {
+	b.dialect = d
+	return b
+}
+
+// From 设置表名
+func (b *SelectBuilder) From(table string) *SelectBuilder {
+	b.table = table
+	return b
+}
+
+// Where 添加查询条件，多次调用之间为 AND 关系；args为切片时，对应的 ? 会展开为 IN 列表
+//  e.g
+//  Where("city = ? AND user_id > ?", "beijing", 1000)
+//  Where("user_id IN (?)", []int{1, 2, 3})
+func (b *SelectBuilder) Where(cond string, args ...interface{}) *SelectBuilder {
+	cond, args = expandArgs(cond, args)
+	b.wheres = append(b.wheres, cond)
+	b.whereArgs = append(b.whereArgs, args...)
+	return b
+}
+
+// Join 添加内连接，on为连接条件
+func (b *SelectBuilder) Join(table, on string, args ...interface{}) *SelectBuilder {
+	return b.join("JOIN", table, on, args)
+}
+
+// LeftJoin 添加左连接，on为连接条件
+func (b *SelectBuilder) LeftJoin(table, on string, args ...interface{}) *SelectBuilder {
+	return b.join("LEFT JOIN", table, on, args)
+}
+
+func (b *SelectBuilder) join(kind, table, on string, args []interface{}) *SelectBuilder {
+	on, args = expandArgs(on, args)
+	b.joins = append(b.joins, fmt.Sprintf("%s %s ON %s", kind, table, on))
+	b.joinArgs = append(b.joinArgs, args...)
+	return b
+}
+
+// GroupBy 设置分组列
+func (b *SelectBuilder) GroupBy(cols ...string) *SelectBuilder {
+	b.groups = append(b.groups, cols...)
+	return b
+}
+
+// OrderBy 设置排序，如 OrderBy("user_id DESC", "city")
+func (b *SelectBuilder) OrderBy(orders ...string) *SelectBuilder {
+	b.orders = append(b.orders, orders...)
+	return b
+}
+
+// Limit 设置返回行数上限，负数表示不限制
+func (b *SelectBuilder) Limit(n int) *SelectBuilder {
+	b.limit = n
+	return b
+}
+
+// Offset 设置跳过的行数，负数表示不设置
+func (b *SelectBuilder) Offset(m int) *SelectBuilder {
+	b.offset = m
+	return b
+}
+
+// ToSQL 生成查询语句及参数
+func (b *SelectBuilder) ToSQL() (string, []interface{}) {
+	cols := "*"
+	if len(b.cols) > 0 {
+		cols = strings.Join(b.cols, ", ")
+	}
+
+	var sb strings.Builder
+	sb.WriteString("SELECT ")
+	sb.WriteString(cols)
+
+	if b.table != "" {
+		sb.WriteString(" FROM ")
+		sb.WriteString(b.table)
+	}
+
+	for _, j := range b.joins {
+		sb.WriteString(" ")
+		sb.WriteString(j)
+	}
+
+	wheres := b.wheres
+	if b.softDelete != "" {
+		wheres = append(wheres[:len(wheres):len(wheres)], b.softDelete)
+	}
+
+	if len(wheres) > 0 {
+		sb.WriteString(" WHERE ")
+		sb.WriteString(joinConds(wheres))
+	}
+
+	if len(b.groups) > 0 {
+		sb.WriteString(" GROUP BY ")
+		sb.WriteString(strings.Join(b.groups, ", "))
+	}
+
+	if len(b.orders) > 0 {
+		sb.WriteString(" ORDER BY ")
+		sb.WriteString(strings.Join(b.orders, ", "))
+	}
+
+	if b.limit >= 0 {
+		sb.WriteString(fmt.Sprintf(" LIMIT %d", b.limit))
+	}
+
+	if b.offset >= 0 {
+		// MySQL 与 SQLite 不支持单独使用 OFFSET
+		if b.limit < 0 && b.dialect == MySQL {
+			sb.WriteString(" LIMIT 18446744073709551615")
+		} else if b.limit < 0 && b.dialect == SQLite {
+			sb.WriteString(" LIMIT -1")
+		}
+		sb.WriteString(fmt.Sprintf(" OFFSET %d", b.offset))
+	}
+
+	args := make([]interface{}, 0, len(b.joinArgs)+len(b.whereArgs))
+	args = append(args, b.joinArgs...)
+	args = append(args, b.whereArgs...)
+
+	return b.dialect.Rebind(sb.String()), args
+}
+
+// CountSQL 生成统计总行数的语句，忽略排序及分页设置；设置了 GroupBy 时统计分组数
+func (b *SelectBuilder) CountSQL() (string, []interface{}) {
+	c := b.clone()
+	c.orders = nil
+	c.limit, c.offset = -1, -1
+
+	if len(c.groups) > 0 {
+		script, args := c.ToSQL()
+		return fmt.Sprintf("SELECT COUNT(*) FROM (%s) t", script), args
+	}
+
+	c.cols = []string{"COUNT(*)"}
+	return c.ToSQL()
+}
+
+func (b *SelectBuilder) clone() *SelectBuilder {
+	c := *b
+	c.cols = append([]string(nil), b.cols...)
+	c.joins = append([]string(nil), b.joins...)
+	c.wheres = append([]string(nil), b.wheres...)
+	c.groups = append([]string(nil), b.groups...)
+	c.orders = append([]string(nil), b.orders...)
+	c.joinArgs = append([]interface{}(nil), b.joinArgs...)
+	c.whereArgs = append([]interface{}(nil), b.whereArgs...)
+	return &c
+}
+
+// joinConds 使用 AND 连接多个条件，多个条件时每个条件加括号，避免 OR 的优先级问题
+func joinConds(conds []string) string {
+	if len(conds) == 1 {
+		return conds[0]
+	}
+
+	return "(" + strings.Join(conds, ") AND (") + ")"
+}
+
+// expandArgs 将切片类型的参数展开，对应的 ? 替换为 ?, ?, ...
+func expandArgs(cond string, args []interface{}) (string, []interface{}) {
+	expand := false
+	for _, arg := range args {
+		if isExpandable(arg) {
+			expand = true
+			break
+		}
+	}
+
+	if !expand {
+		return cond, args
+	}
+
+	var sb strings.Builder
+	var out []interface{}
+
+	n := 0
+	for i := 0; i < len(cond); i++ {
+		if cond[i] != '?' || n >= len(args) {
+			sb.WriteByte(cond[i])
+			continue
+		}
+
+		arg := args[n]
+		n++
+
+		if !isExpandable(arg) {
+			sb.WriteByte('?')
+			out = append(out, arg)
+			continue
+		}
+
+		v := reflect.ValueOf(arg)
+		if v.Len() == 0 {
+			// 空列表，生成恒不成立的条件
+			sb.WriteString("NULL")
+			continue
+		}
+
+		sb.WriteString(strings.TrimSuffix(strings.Repeat("?, ", v.Len()), ", "))
+		for j := 0; j < v.Len(); j++ {
+			out = append(out, v.Index(j).Interface())
+		}
+	}
+
+	return sb.String(), append(out, args[n:]...)
+}
+
+func isExpandable(arg interface{}) bool {
+	if arg == nil {
+		return false
+	}
+
+	t := reflect.TypeOf(arg)
+	return t.Kind() == reflect.Slice && t.Elem().Kind() != reflect.Uint8
+}
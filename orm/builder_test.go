@@ -0,0 +1,57 @@
+package orm_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"util/orm"
+)
+
+var _ = Describe("Builder", func() {
+
+	Context("render sql", func() {
+		It("should be succeed", func() {
+			sql, args := orm.Select("u.user_id", "u.user_name").
+				From("sample u").
+				LeftJoin("orders o", "o.user_id = u.user_id AND o.status = ?", 1).
+				Where("u.city = ?", "beijing").
+				Where("u.user_id IN (?) OR u.user_name = ?", []int{1, 2, 3}, "tom").
+				OrderBy("u.user_id DESC").
+				Limit(10).
+				Offset(20).
+				ToSQL()
+
+			Expect(sql).Should(Equal("SELECT u.user_id, u.user_name FROM sample u " +
+				"LEFT JOIN orders o ON o.user_id = u.user_id AND o.status = ? " +
+				"WHERE (u.city = ?) AND (u.user_id IN (?, ?, ?) OR u.user_name = ?) " +
+				"ORDER BY u.user_id DESC LIMIT 10 OFFSET 20"))
+			Expect(args).Should(Equal([]interface{}{1, "beijing", 1, 2, 3, "tom"}))
+		})
+
+		It("should rebind placeholders by dialect", func() {
+			sql, args := orm.Select().From("sample").Where("city = ? AND user_name <> '?'", "chengdu").
+				Where("user_id > ?", 10).WithDialect(orm.PostgreSQL).ToSQL()
+
+			Expect(sql).Should(Equal("SELECT * FROM sample WHERE (city = $1 AND user_name <> '?') AND (user_id > $2)"))
+			Expect(len(args) == 2).Should(BeTrue())
+
+			sql, _ = orm.Select("id").From("sample").Offset(5).WithDialect(orm.SQLite).ToSQL()
+			Expect(sql).Should(Equal("SELECT id FROM sample LIMIT -1 OFFSET 5"))
+		})
+
+		It("query with model columns", func() {
+			requireMySQL()
+
+			b, err := orm.SelectModel(&UserInfo{}, "db")
+			Expect(err).Should(Succeed())
+
+			sql, args := b.From("sample").Where("user_id = ?", 1086).ToSQL()
+			Expect(sql).Should(Equal("SELECT user_id, user_name, city FROM sample WHERE user_id = ?"))
+
+			records, err := orm.Query(context.TODO(), db, sql, &UserInfo{}, args...)
+			Expect(err).Should(Succeed())
+			Expect(len(records) == 1).Should(BeTrue())
+		})
+	})
+})
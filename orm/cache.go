@@ -0,0 +1,307 @@
+package orm
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"util/primitive"
+)
+
+/*
+ * QueryCache 查询结果缓存，以执行者、sql 语句及参数作为键，不同的连接池或事务之间不共享缓存，读取时先查缓存，未命中再查询数据库并写入缓存；
+ * 通过本包的写入函数（Exec、BatchInsert 等）修改数据时，涉及该表的缓存自动失效；
+ * 在 WithTx 开启的事务中写入时，执行后及事务结束后各失效一次，避免事务期间读取的旧数据在提交后仍被缓存；
+ * 自行开启的事务只在执行后失效，需要在提交后调用 Invalidate
+ *  e.g
+ *  cache := orm.NewQueryCache(1024, 60*1e3)
+ *  records, err := cache.Query(ctx, db, sql, &UserInfo{}, args...)
+ */
+
+const defaultCacheCap = 1024
+
+// lruCache primitive.NewLRU 返回的缓存对象
+type lruCache interface {
+	Get(key interface{}) (interface{}, bool)
+	Put(key interface{}, value interface{})
+	GetLength() int
+}
+
+type cacheEntry struct {
+	records  []interface{}
+	expireAt int64    // 过期时间，unix毫秒，0表示不过期
+	tables   []string // 查询涉及的表
+	gens     []uint64 // 写入缓存时各表的版本
+}
+
+// CacheStats 缓存统计信息
+type CacheStats struct {
+	Hits          uint64 `json:"hits"`          // 命中次数
+	Misses        uint64 `json:"misses"`        // 未命中次数
+	Invalidations uint64 `json:"invalidations"` // 失效次数
+	Entries       int    `json:"entries"`       // 缓存条目数（包含已失效未淘汰的条目）
+}
+
+// QueryCache 查询结果缓存，协程安全
+type QueryCache struct {
+	lru   lruCache
+	ttlMS int64
+
+	gens  map[string]uint64 // 表版本，表数据被修改时版本加1，之前写入的缓存随之失效
+	mutex sync.RWMutex
+
+	hits          uint64
+	misses        uint64
+	invalidations uint64
+}
+
+var (
+	caches      = make(map[*QueryCache]struct{})
+	cachesMutex sync.RWMutex
+
+	txTables      = make(map[*sql.Tx]map[string]struct{}) // WithTx 开启的事务中写入的表
+	txTablesMutex sync.Mutex
+
+	tableReg = regexp.MustCompile("(?i)\\b(?:FROM|JOIN|INTO|UPDATE)\\s+([`\"\\w.]+)")
+)
+
+// NewQueryCache 创建查询缓存，cap为缓存条目上限，ttlMS为缓存存活时间(毫秒)，0表示不过期；
+// 不再使用时需要调用Close
+func NewQueryCache(cap int, ttlMS int64) *QueryCache {
+	if cap <= 0 {
+		cap = defaultCacheCap
+	}
+
+	c := &QueryCache{
+		lru:   primitive.NewLRU(cap, -1),
+		ttlMS: ttlMS,
+		gens:  make(map[string]uint64),
+	}
+
+	cachesMutex.Lock()
+	caches[c] = struct{}{}
+	cachesMutex.Unlock()
+
+	return c
+}
+
+// Close 停止接收写入通知
+func (c *QueryCache) Close() {
+	cachesMutex.Lock()
+	delete(caches, c)
+	cachesMutex.Unlock()
+}
+
+// Query 带缓存的 orm.Query，返回的记录是缓存记录的浅拷贝
+func (c *QueryCache) Query(ctx context.Context, db Executor, sql string, modelPtr interface{}, args ...interface{}) ([]interface{}, error) {
+	return c.QueryTTL(ctx, db, c.ttlMS, sql, modelPtr, args...)
+}
+
+// QueryTTL 带缓存的 orm.Query，ttlMS指定本次查询结果的缓存存活时间(毫秒)，0表示不过期，负数表示不写入缓存
+func (c *QueryCache) QueryTTL(ctx context.Context, db Executor, ttlMS int64, sql string, modelPtr interface{}, args ...interface{}) ([]interface{}, error) {
+	key := cacheKey(db, sql, modelPtr, args)
+
+	if v, ok := c.lru.Get(key); ok {
+		entry := v.(*cacheEntry)
+		if c.valid(entry) {
+			atomic.AddUint64(&c.hits, 1)
+			return copyRecords(entry.records), nil
+		}
+	}
+
+	atomic.AddUint64(&c.misses, 1)
+
+	// 查询前记录表版本，查询期间发生的写入会使本次结果失效
+	tables := parseTables(sql)
+	gens := c.generations(tables)
+
+	records, err := Query(ctx, db, sql, modelPtr, args...)
+	if err != nil || ttlMS < 0 {
+		return records, err
+	}
+
+	entry := &cacheEntry{records: records, tables: tables, gens: gens}
+	if ttlMS > 0 {
+		entry.expireAt = time.Now().UnixNano()/1e6 + ttlMS
+	}
+
+	c.lru.Put(key, entry)
+	return copyRecords(records), nil
+}
+
+// cacheKey 生成缓存键：执行者为指针时按地址区分；参数按驱动的规则转换，
+// driver.Valuer 取其值，指针取其指向的值，避免相等的查询因指针地址不同而无法命中
+func cacheKey(db Executor, sql string, modelPtr interface{}, args []interface{}) string {
+	executor := fmt.Sprintf("%T", db)
+	if v := reflect.ValueOf(db); v.Kind() == reflect.Ptr {
+		executor += fmt.Sprintf("@%p", db)
+	}
+
+	values := make([]interface{}, len(args))
+	for i, arg := range args {
+		values[i] = arg
+		if v, err := driver.DefaultParameterConverter.ConvertValue(arg); err == nil {
+			values[i] = v
+		}
+	}
+
+	return fmt.Sprintf("%s\x00%T\x00%s\x00%#v", executor, modelPtr, sql, values)
+}
+
+// Invalidate 使涉及tables的缓存失效
+func (c *QueryCache) Invalidate(tables ...string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, table := range tables {
+		c.gens[normalizeTable(table)]++
+	}
+
+	atomic.AddUint64(&c.invalidations, 1)
+}
+
+// Stats 缓存统计信息
+func (c *QueryCache) Stats() *CacheStats {
+	return &CacheStats{
+		Hits:          atomic.LoadUint64(&c.hits),
+		Misses:        atomic.LoadUint64(&c.misses),
+		Invalidations: atomic.LoadUint64(&c.invalidations),
+		Entries:       c.lru.GetLength(),
+	}
+}
+
+func (c *QueryCache) valid(entry *cacheEntry) bool {
+	if entry.expireAt > 0 && time.Now().UnixNano()/1e6 > entry.expireAt {
+		return false
+	}
+
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	for i, table := range entry.tables {
+		if c.gens[table] != entry.gens[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (c *QueryCache) generations(tables []string) []uint64 {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	gens := make([]uint64, len(tables))
+	for i, table := range tables {
+		gens[i] = c.gens[table]
+	}
+
+	return gens
+}
+
+// notifyWrite 通知所有查询缓存，tables中的数据已被修改
+func notifyWrite(tables ...string) {
+	if len(tables) == 0 {
+		return
+	}
+
+	cachesMutex.RLock()
+	defer cachesMutex.RUnlock()
+
+	for c := range caches {
+		c.Invalidate(tables...)
+	}
+}
+
+// notifyExec 通知db上的写入，db为 WithTx 开启的事务时记录写入的表，事务结束后再通知一次
+func notifyExec(db Executor, tables ...string) {
+	if tx, ok := db.(*sql.Tx); ok && len(tables) > 0 {
+		txTablesMutex.Lock()
+		if written, ok := txTables[tx]; ok {
+			for _, table := range tables {
+				written[table] = struct{}{}
+			}
+		}
+		txTablesMutex.Unlock()
+	}
+
+	notifyWrite(tables...)
+}
+
+// trackTx 开始记录事务中写入的表
+func trackTx(tx *sql.Tx) {
+	txTablesMutex.Lock()
+	defer txTablesMutex.Unlock()
+
+	txTables[tx] = make(map[string]struct{})
+}
+
+// untrackTx 停止记录并返回事务中写入的表
+func untrackTx(tx *sql.Tx) []string {
+	txTablesMutex.Lock()
+	defer txTablesMutex.Unlock()
+
+	written := txTables[tx]
+	delete(txTables, tx)
+
+	tables := make([]string, 0, len(written))
+	for table := range written {
+		tables = append(tables, table)
+	}
+
+	return tables
+}
+
+// parseTables 解析sql语句中涉及的表名
+func parseTables(sql string) []string {
+	var tables []string
+	seen := make(map[string]bool)
+
+	for _, match := range tableReg.FindAllStringSubmatch(sql, -1) {
+		table := normalizeTable(match[1])
+		if table != "" && !seen[table] {
+			seen[table] = true
+			tables = append(tables, table)
+		}
+	}
+
+	return tables
+}
+
+// normalizeTable 去掉表名的引号及库名前缀，统一为小写
+func normalizeTable(table string) string {
+	table = strings.NewReplacer("`", "", `"`, "").Replace(table)
+	if i := strings.LastIndex(table, "."); i >= 0 {
+		table = table[i+1:]
+	}
+
+	return strings.ToLower(table)
+}
+
+func copyRecords(records []interface{}) []interface{} {
+	if records == nil {
+		return nil
+	}
+
+	cp := make([]interface{}, len(records))
+	for i, record := range records {
+		v := reflect.ValueOf(record)
+		if v.Kind() != reflect.Ptr || v.IsNil() {
+			cp[i] = record
+			continue
+		}
+
+		n := reflect.New(v.Elem().Type())
+		n.Elem().Set(v.Elem())
+		cp[i] = n.Interface()
+	}
+
+	return cp
+}
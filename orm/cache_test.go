@@ -0,0 +1,147 @@
+package orm_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"util/orm"
+	"util/orm/ormtest"
+)
+
+var _ = Describe("Cache", func() {
+
+	BeforeEach(requireMySQL)
+
+	Context("query cache", func() {
+		const script = "SELECT user_id, user_name, city FROM sample WHERE city = ?"
+
+		It("should hit and invalidate on write", func() {
+			cache := orm.NewQueryCache(16, 0)
+			defer cache.Close()
+
+			records, err := cache.Query(context.TODO(), db, script, &UserInfo{}, "cache_city")
+			Expect(err).Should(Succeed())
+			Expect(len(records) == 0).Should(BeTrue())
+
+			_, err = cache.Query(context.TODO(), db, script, &UserInfo{}, "cache_city")
+			Expect(err).Should(Succeed())
+			Expect(cache.Stats().Hits == 1 && cache.Stats().Misses == 1).Should(BeTrue())
+
+			_, err = orm.Exec(context.TODO(), db, fmt.Sprintf(InsertRowsFormat, 8000, "cache", "cache_city"))
+			Expect(err).Should(Succeed())
+
+			records, err = cache.Query(context.TODO(), db, script, &UserInfo{}, "cache_city")
+			Expect(err).Should(Succeed())
+			Expect(len(records) == 1).Should(BeTrue())
+			Expect(cache.Stats().Misses == 2).Should(BeTrue())
+
+			_, err = orm.BatchInsert(context.TODO(), db, "sample", []UserInfo{{UserID: 8001, UserName: "cache", City: "cache_city"}}, 0)
+			Expect(err).Should(Succeed())
+
+			records, err = cache.Query(context.TODO(), db, script, &UserInfo{}, "cache_city")
+			Expect(err).Should(Succeed())
+			Expect(len(records) == 2).Should(BeTrue())
+		})
+
+		It("should expire by ttl", func() {
+			cache := orm.NewQueryCache(16, 60*1e3)
+			defer cache.Close()
+
+			_, err := cache.QueryTTL(context.TODO(), db, 50, script, &UserInfo{}, "beijing")
+			Expect(err).Should(Succeed())
+
+			records, err := cache.Query(context.TODO(), db, script, &UserInfo{}, "beijing")
+			Expect(err).Should(Succeed())
+			Expect(cache.Stats().Hits == 1).Should(BeTrue())
+
+			// 修改返回的记录不影响缓存
+			records[0].(*UserInfo).City = "changed"
+
+			records, err = cache.Query(context.TODO(), db, script, &UserInfo{}, "beijing")
+			Expect(err).Should(Succeed())
+			Expect(records[0].(*UserInfo).City).Should(Equal("beijing"))
+			Expect(cache.Stats().Hits == 2).Should(BeTrue())
+
+			time.Sleep(100 * time.Millisecond)
+			_, err = cache.Query(context.TODO(), db, script, &UserInfo{}, "beijing")
+			Expect(err).Should(Succeed())
+			Expect(cache.Stats().Misses == 2).Should(BeTrue())
+		})
+	})
+})
+
+var _ = Describe("Cache without database", func() {
+	It("should invalidate again after commit", func() {
+		const script = "SELECT user_id, user_name, city FROM sample WHERE user_id = ?"
+
+		mockDB, mock := ormtest.New()
+		defer mockDB.Close()
+
+		cache := orm.NewQueryCache(16, 0)
+		defer cache.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE sample").WillReturnResult(0, 1)
+		mock.ExpectQuery(script).WillReturnRows(ormtest.NewRows("user_id", "user_name", "city").AddRow(1000, "user_0", "beijing"))
+		mock.ExpectCommit()
+		mock.ExpectQuery(script).WillReturnRows(ormtest.NewRows("user_id", "user_name", "city").AddRow(1000, "user_0", "chengdu"))
+
+		err := orm.WithTx(context.TODO(), mockDB, nil, func(tx *sql.Tx) error {
+			if _, err := orm.Exec(context.TODO(), tx, "UPDATE sample SET city = ? WHERE user_id = ?", "chengdu", 1000); err != nil {
+				return err
+			}
+
+			// 其他连接在提交前读取到旧数据并写入缓存
+			_, err := cache.Query(context.TODO(), mockDB, script, &UserInfo{}, 1000)
+			return err
+		})
+		Expect(err).Should(Succeed())
+
+		records, err := cache.Query(context.TODO(), mockDB, script, &UserInfo{}, 1000)
+		Expect(err).Should(Succeed())
+		Expect(records[0].(*UserInfo).City == "chengdu").Should(BeTrue())
+		Expect(cache.Stats().Misses == 2).Should(BeTrue())
+		Expect(mock.ExpectationsWereMet()).Should(Succeed())
+	})
+
+	It("should key by executor and argument value", func() {
+		const script = "SELECT user_id, user_name, city FROM sample WHERE user_id = ?"
+
+		mockDB, mock := ormtest.New()
+		defer mockDB.Close()
+
+		otherDB, other := ormtest.New()
+		defer otherDB.Close()
+
+		cache := orm.NewQueryCache(16, 0)
+		defer cache.Close()
+
+		mock.ExpectQuery(script).WillReturnRows(ormtest.NewRows("user_id", "user_name", "city").AddRow(1000, "user_0", "beijing"))
+		other.ExpectQuery(script).WillReturnRows(ormtest.NewRows("user_id", "user_name", "city").AddRow(1000, "user_0", "shanghai"))
+
+		id := 1000
+		_, err := cache.Query(context.TODO(), mockDB, script, &UserInfo{}, &id)
+		Expect(err).Should(Succeed())
+
+		// 指向相等值的指针、sql.NullInt64 与整型命中同一条缓存
+		another := 1000
+		records, err := cache.Query(context.TODO(), mockDB, script, &UserInfo{}, &another)
+		Expect(err).Should(Succeed())
+		Expect(records[0].(*UserInfo).City == "beijing").Should(BeTrue())
+
+		_, err = cache.Query(context.TODO(), mockDB, script, &UserInfo{}, sql.NullInt64{Int64: 1000, Valid: true})
+		Expect(err).Should(Succeed())
+
+		records, err = cache.Query(context.TODO(), otherDB, script, &UserInfo{}, 1000)
+		Expect(err).Should(Succeed())
+		Expect(records[0].(*UserInfo).City == "shanghai").Should(BeTrue())
+
+		Expect(cache.Stats().Hits == 2 && cache.Stats().Misses == 2).Should(BeTrue())
+		Expect(mock.ExpectationsWereMet()).Should(Succeed())
+		Expect(other.ExpectationsWereMet()).Should(Succeed())
+	})
+})
@@ -0,0 +1,279 @@
+package orm
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+/*
+ * Cluster 读写分离：读语句路由到从库，写语句及事务路由到主库；定时检查从库健康状况，
+ * 连续失败的从库被剔除，恢复后重新加入；没有可用从库时读语句路由到主库。
+ * Cluster 实现了 Executor，可以直接传给本包的所有函数
+ *  e.g
+ *  c := orm.NewCluster(primary, []*sql.DB{replica1, replica2})
+ *  defer c.Close()
+ *
+ *  records, err := orm.Query(ctx, c, sql, &UserInfo{})                           // 从库
+ *  records, err = orm.Query(orm.WithReadYourWrites(ctx), c, sql, &UserInfo{})   // 主库
+ */
+
+// Balance 从库负载均衡策略
+type Balance int
+
+const (
+	RoundRobin Balance = iota // 轮询
+	LeastConn                 // 最少连接数
+)
+
+const (
+	defaultHealthCheckMS    = 5 * 1e3 // 默认健康检查间隔5s
+	defaultFailThreshold    = 3       // 默认连续失败3次剔除
+	defaultHealthCheckTmoMS = 1 * 1e3 // 健康检查超时时间1s
+)
+
+type readYourWritesKey struct{}
+
+// WithReadYourWrites 返回的ctx中执行的读语句路由到主库，用于写入后需要立即读到最新数据的场景
+func WithReadYourWrites(ctx context.Context) context.Context {
+	return context.WithValue(ctx, readYourWritesKey{}, true)
+}
+
+func isReadYourWrites(ctx context.Context) bool {
+	v, _ := ctx.Value(readYourWritesKey{}).(bool)
+	return v
+}
+
+// ReplicaStatus 从库状态
+type ReplicaStatus struct {
+	Index   int  `json:"index"`   // 从库下标
+	Healthy bool `json:"healthy"` // 是否可用
+	Fails   int  `json:"fails"`   // 连续失败次数
+	InUse   int  `json:"in_use"`  // 使用中的连接数
+}
+
+// ClusterOpts 集群选项
+type ClusterOpts struct {
+	balance       *Balance
+	healthCheckMS *int // 健康检查间隔(毫秒)，0表示不检查
+	failThreshold *int // 连续失败多少次剔除
+}
+
+// SetBalance 设置从库负载均衡策略，默认为轮询
+func (opts *ClusterOpts) SetBalance(b Balance) {
+	opts.balance = &b
+}
+
+// SetHealthCheck 设置健康检查间隔(毫秒)及连续失败多少次剔除从库，intervalMS为0表示不做定时检查
+func (opts *ClusterOpts) SetHealthCheck(intervalMS int, failThreshold int) {
+	opts.healthCheckMS = &intervalMS
+	opts.failThreshold = &failThreshold
+}
+
+type replica struct {
+	db      *sql.DB
+	healthy int32 // 1 可用，0 已剔除
+	fails   int32 // 连续失败次数
+}
+
+// Cluster 一主多从数据库集群，协程安全
+type Cluster struct {
+	primary  *sql.DB
+	replicas []*replica
+
+	balance       Balance
+	healthCheckMS int
+	failThreshold int32
+	next          uint64
+
+	shutdown chan struct{}
+	once     sync.Once
+	wg       sync.WaitGroup
+}
+
+// NewCluster 创建集群，primary为主库，replicas为从库；不再使用时需要调用Close停止健康检查
+func NewCluster(primary *sql.DB, replicas []*sql.DB, opts ...ClusterOpts) *Cluster {
+	c := &Cluster{
+		primary:       primary,
+		balance:       RoundRobin,
+		healthCheckMS: defaultHealthCheckMS,
+		failThreshold: defaultFailThreshold,
+		shutdown:      make(chan struct{}),
+	}
+
+	if len(opts) > 0 {
+		opt := opts[0]
+
+		if opt.balance != nil {
+			c.balance = *opt.balance
+		}
+
+		if opt.healthCheckMS != nil {
+			c.healthCheckMS = *opt.healthCheckMS
+		}
+
+		if opt.failThreshold != nil && *opt.failThreshold > 0 {
+			c.failThreshold = int32(*opt.failThreshold)
+		}
+	}
+
+	for _, db := range replicas {
+		c.replicas = append(c.replicas, &replica{db: db, healthy: 1})
+	}
+
+	if c.healthCheckMS > 0 && len(c.replicas) > 0 {
+		c.wg.Add(1)
+		go c.healthCheck()
+	}
+
+	return c
+}
+
+// Primary 返回主库
+func (c *Cluster) Primary() *sql.DB {
+	return c.primary
+}
+
+// Reader 返回读语句使用的数据库
+func (c *Cluster) Reader(ctx context.Context) *sql.DB {
+	if r := c.pick(ctx); r != nil {
+		return r.db
+	}
+
+	return c.primary
+}
+
+// QueryContext 实现 Executor，路由到从库
+func (c *Cluster) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	r := c.pick(ctx)
+	if r == nil {
+		return c.primary.QueryContext(ctx, query, args...)
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil && isConnError(err) {
+		c.markFailure(r)
+	}
+
+	return rows, err
+}
+
+// ExecContext 实现 Executor，路由到主库
+func (c *Cluster) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return c.primary.ExecContext(ctx, query, args...)
+}
+
+// BeginTx 在主库上开启事务
+func (c *Cluster) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	return c.primary.BeginTx(ctx, opts)
+}
+
+// Stats 从库状态
+func (c *Cluster) Stats() []*ReplicaStatus {
+	stats := make([]*ReplicaStatus, 0, len(c.replicas))
+	for i, r := range c.replicas {
+		stats = append(stats, &ReplicaStatus{
+			Index:   i,
+			Healthy: atomic.LoadInt32(&r.healthy) == 1,
+			Fails:   int(atomic.LoadInt32(&r.fails)),
+			InUse:   r.db.Stats().InUse,
+		})
+	}
+
+	return stats
+}
+
+// Close 停止健康检查，不会关闭数据库连接
+func (c *Cluster) Close() {
+	c.once.Do(func() {
+		close(c.shutdown)
+	})
+
+	c.wg.Wait()
+}
+
+// pick 选择从库，强制读主库或没有可用从库时返回nil
+func (c *Cluster) pick(ctx context.Context) *replica {
+	if isReadYourWrites(ctx) {
+		return nil
+	}
+
+	healthy := make([]*replica, 0, len(c.replicas))
+	for _, r := range c.replicas {
+		if atomic.LoadInt32(&r.healthy) == 1 {
+			healthy = append(healthy, r)
+		}
+	}
+
+	if len(healthy) == 0 {
+		return nil
+	}
+
+	if c.balance == LeastConn {
+		best := healthy[0]
+		for _, r := range healthy[1:] {
+			if r.db.Stats().InUse < best.db.Stats().InUse {
+				best = r
+			}
+		}
+		return best
+	}
+
+	n := atomic.AddUint64(&c.next, 1)
+	return healthy[int(n%uint64(len(healthy)))]
+}
+
+func (c *Cluster) markFailure(r *replica) {
+	if atomic.AddInt32(&r.fails, 1) >= c.failThreshold {
+		atomic.StoreInt32(&r.healthy, 0)
+	}
+}
+
+func (c *Cluster) markSuccess(r *replica) {
+	atomic.StoreInt32(&r.fails, 0)
+	atomic.StoreInt32(&r.healthy, 1)
+}
+
+// healthCheck 定时检查所有从库（包括已剔除的），恢复的从库重新加入
+func (c *Cluster) healthCheck() {
+	ticker := time.NewTicker(time.Duration(c.healthCheckMS) * time.Millisecond)
+	defer func() {
+		ticker.Stop()
+		c.wg.Done()
+	}()
+
+	for {
+		select {
+		case <-c.shutdown:
+			return
+
+		case <-ticker.C:
+			for _, r := range c.replicas {
+				ctx, cancel := context.WithTimeout(context.Background(), defaultHealthCheckTmoMS*time.Millisecond)
+				err := r.db.PingContext(ctx)
+				cancel()
+
+				if err != nil {
+					c.markFailure(r)
+				} else {
+					c.markSuccess(r)
+				}
+			}
+		}
+	}
+}
+
+// isConnError 判断是否为连接错误，语法错误等不会导致从库被剔除
+func isConnError(err error) bool {
+	if errors.Is(err, driver.ErrBadConn) {
+		return true
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
@@ -0,0 +1,63 @@
+package orm_test
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"util/orm"
+)
+
+var _ = Describe("Cluster", func() {
+
+	BeforeEach(requireMySQL)
+
+	Context("read write splitting", func() {
+		It("should route reads to replicas and evict failing ones", func() {
+			good, err := sql.Open("mysql", dsn)
+			Expect(err).Should(Succeed())
+			defer good.Close()
+
+			bad, err := sql.Open("mysql", "root:@tcp(localhost:1)/util?timeout=100ms")
+			Expect(err).Should(Succeed())
+			defer bad.Close()
+
+			opts := orm.ClusterOpts{}
+			opts.SetHealthCheck(50, 1)
+
+			c := orm.NewCluster(db, []*sql.DB{good, bad}, opts)
+			defer c.Close()
+
+			Expect(c.Reader(orm.WithReadYourWrites(context.TODO())) == db).Should(BeTrue())
+
+			time.Sleep(300 * time.Millisecond)
+
+			stats := c.Stats()
+			Expect(stats[0].Healthy).Should(BeTrue())
+			Expect(stats[1].Healthy).Should(BeFalse())
+
+			for i := 0; i < 4; i++ {
+				Expect(c.Reader(context.TODO()) == good).Should(BeTrue())
+
+				records, err := orm.Query(context.TODO(), c, "SELECT user_id, user_name, city FROM sample WHERE user_id = ?", &UserInfo{}, 1002)
+				Expect(err).Should(Succeed())
+				Expect(len(records) == 1).Should(BeTrue())
+			}
+		})
+
+		It("should fall back to primary without replicas", func() {
+			opts := orm.ClusterOpts{}
+			opts.SetBalance(orm.LeastConn)
+
+			c := orm.NewCluster(db, nil, opts)
+			defer c.Close()
+
+			Expect(c.Reader(context.TODO()) == db).Should(BeTrue())
+
+			_, err := orm.Exec(context.TODO(), c, "UPDATE sample SET city = city WHERE user_id = ?", 1002)
+			Expect(err).Should(Succeed())
+		})
+	})
+})
@@ -0,0 +1,336 @@
+package orm
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+/*
+ * 数据对象约定，按"db"描述符识别，均为可选：
+ *  created_at  写入时自动填充当前时间（已有值时不覆盖）
+ *  updated_at  写入及更新时自动填充当前时间
+ *  deleted_at  软删除，Delete 时填充删除时间而非删除行；SelectModel 生成的语句过滤已删除的行
+ *  version     乐观锁，写入时为1，Update 时校验版本并加1，版本不一致返回 *ConflictError
+ *
+ * 时间列支持 time.Time、*time.Time、sql.NullTime 及 int64、*int64（unix秒）类型，deleted_at 需要是可以为空的类型；
+ * version 列需要是整型
+ *  e.g
+ *  type Order struct {
+ *      ID        int64      `db:"id" orm:"pk;auto_increment"`
+ *      Amount    int        `db:"amount"`
+ *      Version   int64      `db:"version"`
+ *      CreatedAt time.Time  `db:"created_at"`
+ *      UpdatedAt time.Time  `db:"updated_at"`
+ *      DeletedAt *time.Time `db:"deleted_at"`
+ *  }
+ */
+
+const (
+	ColCreatedAt = "created_at"
+	ColUpdatedAt = "updated_at"
+	ColDeletedAt = "deleted_at"
+	ColVersion   = "version"
+)
+
+// ErrConflict 乐观锁冲突，可以通过 errors.Is 判断
+var ErrConflict = errors.New("optimistic lock conflict")
+
+// ConflictError 更新时数据已被其他请求修改（或已被删除）
+type ConflictError struct {
+	Table   string
+	Version int64 // 更新前数据对象的版本
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("%s: version %d of %s has changed", ErrConflict, e.Version, e.Table)
+}
+
+func (e *ConflictError) Is(target error) bool {
+	return target == ErrConflict
+}
+
+// ErrNotFound 删除时行不存在（或已被软删除）
+var ErrNotFound = errors.New("record not found")
+
+// WriteOpts Insert、Update、Delete 及 HardDelete 的选项
+type WriteOpts struct {
+	dialect *Dialect
+}
+
+// SetDialect 设置数据库方言，决定占位符的书写方式，默认为 MySQL
+func (opts *WriteOpts) SetDialect(dialect Dialect) {
+	opts.dialect = &dialect
+}
+
+func writeDialect(opts []WriteOpts) Dialect {
+	if len(opts) > 0 && opts[0].dialect != nil {
+		return *opts[0].dialect
+	}
+
+	return MySQL
+}
+
+// Insert 写入一行数据，modelPtr为数据对象的指针，表名取自 TableName 或结构体名；
+// 值为零的自增主键不写入，写入成功后回填 LastInsertId
+func Insert(ctx context.Context, db Executor, modelPtr interface{}, opts ...WriteOpts) error {
+	m, err := parseModel(modelPtr)
+	if err != nil {
+		return err
+	}
+
+	v := reflect.ValueOf(modelPtr).Elem()
+	m.fillInsert(v, time.Now())
+
+	var cols []string
+	var args []interface{}
+	var autoField *fieldInfo
+	for _, f := range m.fields {
+		if f.autoIncrement && v.Field(f.index).IsZero() {
+			autoField = f
+			continue
+		}
+
+		cols = append(cols, f.column)
+		args = append(args, v.Field(f.index).Interface())
+	}
+
+	script := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", m.table, strings.Join(cols, ", "),
+		strings.TrimSuffix(strings.Repeat("?, ", len(cols)), ", "))
+
+	result, err := Exec(ctx, db, writeDialect(opts).Rebind(script), args...)
+	if err != nil {
+		return err
+	}
+
+	if autoField != nil {
+		if id, err := result.LastInsertId(); err == nil {
+			setInt(v.Field(autoField.index), id)
+		}
+	}
+
+	return nil
+}
+
+// Update 按主键更新除主键及 created_at 以外的所有列；数据对象包含 version 列时校验版本，
+// 版本不一致（或行不存在、已被软删除）返回 *ConflictError，更新成功后数据对象的版本加1；
+// 更新失败时数据对象的 updated_at 保持原值
+func Update(ctx context.Context, db Executor, modelPtr interface{}, opts ...WriteOpts) (err error) {
+	m, err := parseModel(modelPtr)
+	if err != nil {
+		return err
+	}
+
+	pks := m.pk()
+	if len(pks) == 0 {
+		return fmt.Errorf("%s: no primary key", m.table)
+	}
+
+	v := reflect.ValueOf(modelPtr).Elem()
+	if f := m.field(ColUpdatedAt); f != nil {
+		field := v.Field(f.index)
+		old := reflect.New(field.Type()).Elem()
+		old.Set(field)
+		defer func() {
+			if err != nil {
+				field.Set(old)
+			}
+		}()
+
+		setTime(field, time.Now())
+	}
+
+	version := m.field(ColVersion)
+
+	var sets []string
+	var args []interface{}
+	for _, f := range m.fields {
+		if f.pk || f.column == ColCreatedAt || f == version {
+			continue
+		}
+
+		sets = append(sets, f.column+" = ?")
+		args = append(args, v.Field(f.index).Interface())
+	}
+
+	if version != nil {
+		sets = append(sets, fmt.Sprintf("%s = %s + 1", ColVersion, ColVersion))
+	}
+
+	conds, condArgs := m.pkConds(v)
+	if version != nil {
+		conds = append(conds, ColVersion+" = ?")
+		condArgs = append(condArgs, v.Field(version.index).Interface())
+	}
+
+	if f := m.field(ColDeletedAt); f != nil {
+		conds = append(conds, ColDeletedAt+" IS NULL")
+	}
+
+	script := fmt.Sprintf("UPDATE %s SET %s WHERE %s", m.table, strings.Join(sets, ", "), strings.Join(conds, " AND "))
+	result, err := Exec(ctx, db, writeDialect(opts).Rebind(script), append(args, condArgs...)...)
+	if err != nil {
+		return err
+	}
+
+	if version == nil {
+		return nil
+	}
+
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return &ConflictError{Table: m.table, Version: getInt(v.Field(version.index))}
+	}
+
+	setInt(v.Field(version.index), getInt(v.Field(version.index))+1)
+	return nil
+}
+
+// Delete 按主键删除一行数据；数据对象包含 deleted_at 列时为软删除，只填充删除时间（及 updated_at）；
+// 行不存在或已被软删除时返回 ErrNotFound
+func Delete(ctx context.Context, db Executor, modelPtr interface{}, opts ...WriteOpts) error {
+	m, err := parseModel(modelPtr)
+	if err != nil {
+		return err
+	}
+
+	f := m.field(ColDeletedAt)
+	if f == nil {
+		return HardDelete(ctx, db, modelPtr, opts...)
+	}
+
+	if len(m.pk()) == 0 {
+		return fmt.Errorf("%s: no primary key", m.table)
+	}
+
+	v := reflect.ValueOf(modelPtr).Elem()
+	now := time.Now()
+
+	sets := []string{ColDeletedAt + " = ?"}
+	args := []interface{}{timeValue(f.typ, now).Interface()}
+	if updated := m.field(ColUpdatedAt); updated != nil {
+		sets = append(sets, ColUpdatedAt+" = ?")
+		args = append(args, timeValue(updated.typ, now).Interface())
+	}
+
+	conds, condArgs := m.pkConds(v)
+	conds = append(conds, ColDeletedAt+" IS NULL")
+
+	script := fmt.Sprintf("UPDATE %s SET %s WHERE %s", m.table, strings.Join(sets, ", "), strings.Join(conds, " AND "))
+	result, err := Exec(ctx, db, writeDialect(opts).Rebind(script), append(args, condArgs...)...)
+	if err := deleted(m, result, err); err != nil {
+		return err
+	}
+
+	setTime(v.Field(f.index), now)
+	return nil
+}
+
+// HardDelete 按主键删除一行数据，忽略软删除约定；行不存在时返回 ErrNotFound
+func HardDelete(ctx context.Context, db Executor, modelPtr interface{}, opts ...WriteOpts) error {
+	m, err := parseModel(modelPtr)
+	if err != nil {
+		return err
+	}
+
+	if len(m.pk()) == 0 {
+		return fmt.Errorf("%s: no primary key", m.table)
+	}
+
+	conds, args := m.pkConds(reflect.ValueOf(modelPtr).Elem())
+	script := fmt.Sprintf("DELETE FROM %s WHERE %s", m.table, strings.Join(conds, " AND "))
+	result, err := Exec(ctx, db, writeDialect(opts).Rebind(script), args...)
+	return deleted(m, result, err)
+}
+
+// deleted 检查删除语句的执行结果，没有删除任何行时返回 ErrNotFound
+func deleted(m *modelInfo, result sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return fmt.Errorf("%s: %w", m.table, ErrNotFound)
+	}
+
+	return nil
+}
+
+func (m *modelInfo) pkConds(v reflect.Value) ([]string, []interface{}) {
+	var conds []string
+	var args []interface{}
+	for _, f := range m.pk() {
+		conds = append(conds, f.column+" = ?")
+		args = append(args, v.Field(f.index).Interface())
+	}
+
+	return conds, args
+}
+
+// fillInsert 写入前填充 created_at、updated_at 及 version，v为结构体
+func (m *modelInfo) fillInsert(v reflect.Value, now time.Time) {
+	if f := m.field(ColCreatedAt); f != nil && v.Field(f.index).IsZero() {
+		setTime(v.Field(f.index), now)
+	}
+
+	if f := m.field(ColUpdatedAt); f != nil {
+		setTime(v.Field(f.index), now)
+	}
+
+	if f := m.field(ColVersion); f != nil && v.Field(f.index).IsZero() {
+		setInt(v.Field(f.index), 1)
+	}
+}
+
+// timeValue 按成员类型构造时间值
+func timeValue(t reflect.Type, now time.Time) reflect.Value {
+	switch {
+	case t == timeType:
+		return reflect.ValueOf(now)
+	case t == reflect.PtrTo(timeType):
+		return reflect.ValueOf(&now)
+	case t == reflect.TypeOf(sql.NullTime{}):
+		return reflect.ValueOf(sql.NullTime{Time: now, Valid: true})
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Int64:
+		return reflect.ValueOf(now.Unix()).Convert(t)
+	case t.Kind() == reflect.Ptr && t.Elem().Kind() >= reflect.Int && t.Elem().Kind() <= reflect.Int64:
+		v := reflect.New(t.Elem())
+		v.Elem().SetInt(now.Unix())
+		return v
+	}
+
+	return reflect.Zero(t)
+}
+
+func setTime(field reflect.Value, now time.Time) {
+	if field.CanSet() {
+		field.Set(timeValue(field.Type(), now))
+	}
+}
+
+func getInt(field reflect.Value) int64 {
+	switch field.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return field.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(field.Uint())
+	}
+
+	return 0
+}
+
+func setInt(field reflect.Value, n int64) {
+	switch field.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		field.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		field.SetUint(uint64(n))
+	}
+}
@@ -0,0 +1,160 @@
+package orm_test
+
+import (
+	"context"
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"util/orm"
+	"util/orm/ormtest"
+)
+
+type Wallet struct {
+	ID        int64  `db:"id" orm:"pk;auto_increment"`
+	Owner     string `db:"owner" orm:"size:64"`
+	Balance   int    `db:"balance"`
+	Version   int64  `db:"version"`
+	CreatedAt int64  `db:"created_at"`
+	UpdatedAt int64  `db:"updated_at"`
+	DeletedAt *int64 `db:"deleted_at"`
+}
+
+var _ = Describe("Convention", func() {
+
+	BeforeEach(requireMySQL)
+
+	BeforeEach(func() {
+		script, err := orm.CreateTableSQL(&Wallet{}, orm.MySQL)
+		Expect(err).Should(Succeed())
+
+		for _, stmt := range orm.SplitStatements(script) {
+			_, err = db.Exec(stmt)
+			Expect(err).Should(Succeed())
+		}
+	})
+
+	AfterEach(func() {
+		_, err := db.Exec("DROP TABLE wallet")
+		Expect(err).Should(Succeed())
+	})
+
+	Context("insert and update", func() {
+		It("should fill timestamps and version", func() {
+			w := &Wallet{Owner: "alice", Balance: 100}
+			Expect(orm.Insert(context.TODO(), db, w)).Should(Succeed())
+			Expect(w.ID > 0 && w.Version == 1).Should(BeTrue())
+			Expect(w.CreatedAt > 0 && w.CreatedAt == w.UpdatedAt).Should(BeTrue())
+
+			w.Balance = 80
+			Expect(orm.Update(context.TODO(), db, w)).Should(Succeed())
+			Expect(w.Version == 2).Should(BeTrue())
+
+			wallets := []*Wallet{{Owner: "bob"}, {Owner: "carol"}}
+			_, err := orm.BatchInsert(context.TODO(), db, "wallet", wallets, 0)
+			Expect(err).Should(Succeed())
+			Expect(wallets[1].Version == 1 && wallets[1].CreatedAt > 0).Should(BeTrue())
+		})
+
+		It("should fail with conflict error when the row changed", func() {
+			w := &Wallet{Owner: "alice", Balance: 100}
+			Expect(orm.Insert(context.TODO(), db, w)).Should(Succeed())
+
+			stale := *w
+			w.Balance = 80
+			Expect(orm.Update(context.TODO(), db, w)).Should(Succeed())
+
+			stale.Balance = 50
+			err := orm.Update(context.TODO(), db, &stale)
+			Expect(errors.Is(err, orm.ErrConflict)).Should(BeTrue())
+
+			var conflict *orm.ConflictError
+			Expect(errors.As(err, &conflict) && conflict.Version == 1).Should(BeTrue())
+		})
+	})
+
+	Context("soft delete", func() {
+		It("should be filtered out of reads", func() {
+			alice, bob := &Wallet{Owner: "alice"}, &Wallet{Owner: "bob"}
+			Expect(orm.Insert(context.TODO(), db, alice)).Should(Succeed())
+			Expect(orm.Insert(context.TODO(), db, bob)).Should(Succeed())
+
+			Expect(orm.Delete(context.TODO(), db, alice)).Should(Succeed())
+			Expect(alice.DeletedAt != nil).Should(BeTrue())
+
+			b, err := orm.SelectModel(&Wallet{}, "db")
+			Expect(err).Should(Succeed())
+
+			script, args := b.From("wallet").ToSQL()
+			Expect(script).Should(HaveSuffix("WHERE deleted_at IS NULL"))
+
+			records, err := orm.Query(context.TODO(), db, script, &Wallet{}, args...)
+			Expect(err).Should(Succeed())
+			Expect(len(records) == 1 && records[0].(*Wallet).Owner == "bob").Should(BeTrue())
+
+			// 分页在过滤之后进行
+			script, args = b.OrderBy("id").Limit(1).ToSQL()
+			records, err = orm.Query(context.TODO(), db, script, &Wallet{}, args...)
+			Expect(err).Should(Succeed())
+			Expect(len(records) == 1 && records[0].(*Wallet).Owner == "bob").Should(BeTrue())
+
+			b, _ = orm.SelectModel(&Wallet{}, "db")
+			script, args = b.From("wallet").Unscoped().ToSQL()
+			records, err = orm.Query(context.TODO(), db, script, &Wallet{}, args...)
+			Expect(err).Should(Succeed())
+			Expect(len(records) == 2).Should(BeTrue())
+
+			Expect(errors.Is(orm.Update(context.TODO(), db, alice), orm.ErrConflict)).Should(BeTrue())
+		})
+	})
+})
+
+type Counter struct {
+	ID      int64  `db:"id" orm:"pk"`
+	Hits    int    `db:"hits"`
+	Version uint64 `db:"version"`
+}
+
+var _ = Describe("Convention without database", func() {
+	It("should accept unsigned version", func() {
+		mockDB, mock := ormtest.New()
+		defer mockDB.Close()
+
+		mock.ExpectExec("UPDATE").WithArgs(5, int64(1), uint64(3)).WillReturnResult(0, 1)
+		mock.ExpectExec("UPDATE").WithArgs(6, int64(1), uint64(4)).WillReturnResult(0, 0)
+
+		c := &Counter{ID: 1, Hits: 5, Version: 3}
+		Expect(orm.Update(context.TODO(), mockDB, c)).Should(Succeed())
+		Expect(c.Version == 4).Should(BeTrue())
+
+		c.Hits = 6
+		err := orm.Update(context.TODO(), mockDB, c)
+
+		var conflict *orm.ConflictError
+		Expect(errors.As(err, &conflict) && conflict.Version == 4).Should(BeTrue())
+		Expect(mock.ExpectationsWereMet()).Should(Succeed())
+	})
+
+	It("should rebind placeholders and keep the model unchanged on failure", func() {
+		mockDB, mock := ormtest.New()
+		defer mockDB.Close()
+
+		mock.ExpectExec("UPDATE wallet SET owner = $1, balance = $2, updated_at = $3, deleted_at = $4, version = version + 1 WHERE id = $5 AND version = $6").
+			WillReturnResult(0, 0)
+		mock.ExpectExec("UPDATE wallet SET deleted_at = $1, updated_at = $2 WHERE id = $3 AND deleted_at IS NULL").WillReturnResult(0, 0)
+		mock.ExpectExec("DELETE FROM counter WHERE id = $1").WithArgs(int64(1)).WillReturnResult(0, 0)
+
+		opts := orm.WriteOpts{}
+		opts.SetDialect(orm.PostgreSQL)
+
+		w := &Wallet{ID: 1, Owner: "alice", Version: 2, UpdatedAt: 100}
+		Expect(errors.Is(orm.Update(context.TODO(), mockDB, w, opts), orm.ErrConflict)).Should(BeTrue())
+		Expect(w.UpdatedAt == 100 && w.Version == 2).Should(BeTrue())
+
+		Expect(errors.Is(orm.Delete(context.TODO(), mockDB, w, opts), orm.ErrNotFound)).Should(BeTrue())
+		Expect(w.DeletedAt == nil).Should(BeTrue())
+
+		Expect(errors.Is(orm.HardDelete(context.TODO(), mockDB, &Counter{ID: 1}, opts), orm.ErrNotFound)).Should(BeTrue())
+		Expect(mock.ExpectationsWereMet()).Should(Succeed())
+	})
+})
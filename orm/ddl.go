@@ -0,0 +1,410 @@
+package orm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+/*
+ * 根据数据对象生成建表语句，并可以与数据库中的表结构比较，找出差异
+ */
+
+// DriftKind 表结构差异类型
+type DriftKind string
+
+const (
+	DriftMissingColumn DriftKind = "missing_column" // 数据对象中有，表中没有
+	DriftExtraColumn   DriftKind = "extra_column"   // 表中有，数据对象中没有
+	DriftType          DriftKind = "type"           // 列类型不一致
+	DriftNullable      DriftKind = "nullable"       // 是否可以为空不一致
+)
+
+// ColumnDrift 列差异
+type ColumnDrift struct {
+	Kind     DriftKind `json:"kind"`
+	Column   string    `json:"column"`
+	Expected string    `json:"expected"` // 数据对象期望的值
+	Actual   string    `json:"actual"`   // 表中实际的值
+}
+
+func (d *ColumnDrift) String() string {
+	return fmt.Sprintf("%s %s: expected %q, actual %q", d.Kind, d.Column, d.Expected, d.Actual)
+}
+
+var (
+	timeType  = reflect.TypeOf(time.Time{})
+	bytesType = reflect.TypeOf([]byte(nil))
+)
+
+// CreateTableSQL 生成建表语句，modelPtr为数据对象的指针；返回的语句可能包含多条（建表及建索引），
+// 以 ; 分隔，可以使用 SplitStatements 拆分后逐条执行；MySQL 不支持 CREATE INDEX IF NOT EXISTS，索引定义在建表语句中，
+// 因此各方言生成的语句都可以重复执行
+func CreateTableSQL(modelPtr interface{}, dialect Dialect) (string, error) {
+	m, err := parseModel(modelPtr)
+	if err != nil {
+		return "", err
+	}
+
+	if len(m.fields) == 0 {
+		return "", errors.New("no columns found")
+	}
+
+	pks := m.pk()
+
+	var defs []string
+	for _, f := range m.fields {
+		def, err := columnDef(f, dialect, len(pks) == 1)
+		if err != nil {
+			return "", err
+		}
+		defs = append(defs, def)
+	}
+
+	// SQLite 的自增列必须在列定义中声明主键
+	inlinePK := len(pks) == 1 && pks[0].autoIncrement && dialect == SQLite
+	if len(pks) > 0 && !inlinePK {
+		cols := make([]string, 0, len(pks))
+		for _, f := range pks {
+			cols = append(cols, dialect.Quote(f.column))
+		}
+		defs = append(defs, fmt.Sprintf("PRIMARY KEY (%s)", strings.Join(cols, ", ")))
+	}
+
+	var indexStmts []string
+	for _, idx := range tableIndexes(m, dialect) {
+		if dialect == MySQL {
+			kind := "KEY"
+			if idx.unique {
+				kind = "UNIQUE KEY"
+			}
+			defs = append(defs, fmt.Sprintf("%s %s (%s)", kind, dialect.Quote(idx.name), strings.Join(idx.cols, ", ")))
+			continue
+		}
+
+		kind := "INDEX"
+		if idx.unique {
+			kind = "UNIQUE INDEX"
+		}
+		indexStmts = append(indexStmts, fmt.Sprintf("CREATE %s IF NOT EXISTS %s ON %s (%s)",
+			kind, dialect.Quote(idx.name), dialect.Quote(m.table), strings.Join(idx.cols, ", ")))
+	}
+
+	stmts := []string{fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (\n  %s\n)", dialect.Quote(m.table), strings.Join(defs, ",\n  "))}
+	stmts = append(stmts, indexStmts...)
+
+	return strings.Join(stmts, ";\n") + ";", nil
+}
+
+func columnDef(f *fieldInfo, dialect Dialect, singlePK bool) (string, error) {
+	sqlType := f.sqlType
+	if sqlType == "" {
+		var err error
+		if sqlType, err = columnType(f, dialect); err != nil {
+			return "", err
+		}
+	}
+
+	parts := []string{dialect.Quote(f.column), sqlType}
+
+	if f.autoIncrement {
+		switch dialect {
+		case MySQL:
+			parts = append(parts, "NOT NULL AUTO_INCREMENT")
+			return strings.Join(parts, " "), nil
+		case SQLite:
+			if !singlePK || !f.pk {
+				return "", fmt.Errorf("column %s: sqlite auto_increment column must be the only primary key", f.column)
+			}
+			return fmt.Sprintf("%s INTEGER PRIMARY KEY AUTOINCREMENT", dialect.Quote(f.column)), nil
+		case PostgreSQL:
+			// 自增由 SERIAL 类型实现
+			return strings.Join(append(parts, "NOT NULL"), " "), nil
+		}
+	}
+
+	if f.nullable {
+		parts = append(parts, "NULL")
+	} else {
+		parts = append(parts, "NOT NULL")
+	}
+
+	if f.def != nil {
+		parts = append(parts, "DEFAULT "+*f.def)
+	}
+
+	return strings.Join(parts, " "), nil
+}
+
+// columnType 根据成员类型推导列类型
+func columnType(f *fieldInfo, dialect Dialect) (string, error) {
+	t := f.typ
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	// sql.NullXXX 以其值类型推导
+	if t.PkgPath() == "database/sql" && strings.HasPrefix(t.Name(), "Null") && t.Kind() == reflect.Struct {
+		t = t.Field(0).Type
+	}
+
+	if f.autoIncrement && dialect == PostgreSQL {
+		if t.Kind() == reflect.Int64 || t.Kind() == reflect.Uint64 {
+			return "BIGSERIAL", nil
+		}
+		return "SERIAL", nil
+	}
+
+	if f.autoIncrement && dialect == SQLite {
+		return "INTEGER", nil
+	}
+
+	unsigned := ""
+	if dialect == MySQL {
+		unsigned = " UNSIGNED"
+	}
+
+	switch {
+	case t == timeType:
+		return map[Dialect]string{MySQL: "DATETIME", PostgreSQL: "TIMESTAMP", SQLite: "DATETIME"}[dialect], nil
+	case t == bytesType:
+		return map[Dialect]string{MySQL: "BLOB", PostgreSQL: "BYTEA", SQLite: "BLOB"}[dialect], nil
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		if dialect == MySQL {
+			return "TINYINT(1)", nil
+		}
+		return "BOOLEAN", nil
+	case reflect.Int8, reflect.Int16:
+		return "SMALLINT", nil
+	case reflect.Uint8, reflect.Uint16:
+		return "SMALLINT" + unsigned, nil
+	case reflect.Int32, reflect.Int:
+		if dialect == MySQL {
+			return "INT", nil
+		}
+		return "INTEGER", nil
+	case reflect.Uint32, reflect.Uint:
+		if dialect == MySQL {
+			return "INT UNSIGNED", nil
+		}
+		return "BIGINT", nil
+	case reflect.Int64:
+		return "BIGINT", nil
+	case reflect.Uint64:
+		return "BIGINT" + unsigned, nil
+	case reflect.Float32:
+		if dialect == MySQL {
+			return "FLOAT", nil
+		}
+		return "REAL", nil
+	case reflect.Float64:
+		if dialect == PostgreSQL {
+			return "DOUBLE PRECISION", nil
+		}
+		if dialect == SQLite {
+			return "REAL", nil
+		}
+		return "DOUBLE", nil
+	case reflect.String:
+		if f.size > 0 {
+			return fmt.Sprintf("VARCHAR(%d)", f.size), nil
+		}
+		if dialect == MySQL {
+			return "VARCHAR(255)", nil
+		}
+		return "TEXT", nil
+	}
+
+	return "", fmt.Errorf("column %s: unsupported type %s", f.column, f.typ)
+}
+
+type tableIndex struct {
+	name   string
+	unique bool
+	cols   []string // 已加引号的列名
+}
+
+// tableIndexes 按声明顺序返回索引，同名索引的列按成员顺序组成联合索引
+func tableIndexes(m *modelInfo, dialect Dialect) []*tableIndex {
+	indexes := make(map[string]*tableIndex)
+	var names []string
+
+	add := func(name string, unique bool, f *fieldInfo) {
+		if name == "-" {
+			name = fmt.Sprintf("idx_%s_%s", m.table, f.column)
+			if unique {
+				name = fmt.Sprintf("uk_%s_%s", m.table, f.column)
+			}
+		}
+
+		idx, ok := indexes[name]
+		if !ok {
+			idx = &tableIndex{name: name, unique: unique}
+			indexes[name] = idx
+			names = append(names, name)
+		}
+		idx.cols = append(idx.cols, dialect.Quote(f.column))
+	}
+
+	for _, f := range m.fields {
+		if f.indexName != "" {
+			add(f.indexName, false, f)
+		}
+		if f.uniqueName != "" {
+			add(f.uniqueName, true, f)
+		}
+	}
+
+	list := make([]*tableIndex, 0, len(names))
+	for _, name := range names {
+		list = append(list, indexes[name])
+	}
+
+	return list
+}
+
+type liveColumn struct {
+	name     string
+	sqlType  string
+	nullable bool
+}
+
+// DiffTable 比较数据对象与数据库中表结构的差异，MySQL 及 PostgreSQL 通过 information_schema 查询，SQLite 通过 pragma_table_info 查询；
+// 表不存在时所有列均为 DriftMissingColumn
+func DiffTable(ctx context.Context, db Executor, modelPtr interface{}, dialect Dialect) ([]*ColumnDrift, error) {
+	m, err := parseModel(modelPtr)
+	if err != nil {
+		return nil, err
+	}
+
+	live, err := liveColumns(ctx, db, m.table, dialect)
+	if err != nil {
+		return nil, err
+	}
+
+	var drifts []*ColumnDrift
+	for _, f := range m.fields {
+		col, ok := live[f.column]
+		if !ok {
+			drifts = append(drifts, &ColumnDrift{Kind: DriftMissingColumn, Column: f.column})
+			continue
+		}
+		delete(live, f.column)
+
+		expected := f.sqlType
+		if expected == "" {
+			if expected, err = columnType(f, dialect); err != nil {
+				return nil, err
+			}
+		}
+
+		if normalizeType(expected, dialect) != normalizeType(col.sqlType, dialect) {
+			drifts = append(drifts, &ColumnDrift{Kind: DriftType, Column: f.column, Expected: expected, Actual: col.sqlType})
+		}
+
+		// 主键列在 SQLite 中可能被报告为可空，不做比较
+		if !f.pk && f.nullable != col.nullable {
+			drifts = append(drifts, &ColumnDrift{Kind: DriftNullable, Column: f.column,
+				Expected: nullableName(f.nullable), Actual: nullableName(col.nullable)})
+		}
+	}
+
+	extras := make([]string, 0, len(live))
+	for name := range live {
+		extras = append(extras, name)
+	}
+	sort.Strings(extras)
+
+	for _, name := range extras {
+		drifts = append(drifts, &ColumnDrift{Kind: DriftExtraColumn, Column: name, Actual: live[name].sqlType})
+	}
+
+	return drifts, nil
+}
+
+func liveColumns(ctx context.Context, db Executor, table string, dialect Dialect) (map[string]*liveColumn, error) {
+	var script string
+	switch dialect {
+	case MySQL:
+		script = "SELECT column_name, column_type, is_nullable = 'YES' FROM information_schema.columns " +
+			"WHERE table_schema = DATABASE() AND table_name = ?"
+	case PostgreSQL:
+		script = "SELECT column_name, CASE WHEN character_maximum_length IS NULL THEN udt_name " +
+			"ELSE udt_name || '(' || character_maximum_length || ')' END, is_nullable = 'YES' FROM information_schema.columns " +
+			"WHERE table_schema = current_schema() AND table_name = $1"
+	case SQLite:
+		script = `SELECT name, type, "notnull" = 0 FROM pragma_table_info(?)`
+	default:
+		return nil, fmt.Errorf("unsupported dialect %s", dialect)
+	}
+
+	rows, err := db.QueryContext(ctx, script, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cols := make(map[string]*liveColumn)
+	for rows.Next() {
+		col := &liveColumn{}
+		if err := rows.Scan(&col.name, &col.sqlType, &col.nullable); err != nil {
+			return nil, err
+		}
+		cols[col.name] = col
+	}
+
+	return cols, rows.Err()
+}
+
+var (
+	intWidthReg = regexp.MustCompile(`^(smallint|mediumint|int|bigint)\(\d+\)`)
+	typeAliases = map[string]string{
+		"int2":             "smallint",
+		"int4":             "integer",
+		"int8":             "bigint",
+		"serial":           "integer",
+		"bigserial":        "bigint",
+		"bool":             "boolean",
+		"float4":           "real",
+		"float8":           "double precision",
+		"timestamp":        "timestamp",
+		"double precision": "double precision",
+	}
+)
+
+// normalizeType 统一类型写法，忽略大小写、MySQL 整型显示宽度及 PostgreSQL 内部类型名的差异
+func normalizeType(sqlType string, dialect Dialect) string {
+	t := strings.ToLower(strings.TrimSpace(sqlType))
+	t = strings.Join(strings.Fields(t), " ")
+
+	if dialect == MySQL {
+		return intWidthReg.ReplaceAllString(t, "$1")
+	}
+
+	if dialect == PostgreSQL {
+		if strings.HasPrefix(t, "character varying") {
+			t = "varchar" + strings.TrimPrefix(t, "character varying")
+		}
+		if alias, ok := typeAliases[t]; ok {
+			t = alias
+		}
+	}
+
+	return t
+}
+
+func nullableName(nullable bool) string {
+	if nullable {
+		return "NULL"
+	}
+
+	return "NOT NULL"
+}
@@ -0,0 +1,108 @@
+package orm_test
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"util/orm"
+)
+
+type Account struct {
+	ID        int64          `db:"id" orm:"pk;auto_increment"`
+	Name      string         `db:"name" orm:"size:64;index:idx_name_age"`
+	Age       int            `db:"age" orm:"default:0;index:idx_name_age"`
+	Email     *string        `db:"email" orm:"size:128;unique"`
+	Nick      sql.NullString `db:"nick"`
+	Balance   float64        `db:"balance" orm:"type:DECIMAL(10,2)"`
+	CreatedAt time.Time      `db:"created_at"`
+}
+
+type SampleRow struct {
+	ID       int64  `db:"id" orm:"pk;auto_increment"`
+	UserID   int    `db:"user_id"`
+	UserName string `db:"user_name"`
+	City     string `db:"city" orm:"type:TEXT"`
+}
+
+func (r *SampleRow) TableName() string {
+	return "sample"
+}
+
+var _ = Describe("DDL", func() {
+
+	Context("create table sql", func() {
+		It("should be succeed", func() {
+			script, err := orm.CreateTableSQL(&Account{}, orm.MySQL)
+			Expect(err).Should(Succeed())
+			Expect(script).Should(Equal("CREATE TABLE IF NOT EXISTS `account` (\n" +
+				"  `id` BIGINT NOT NULL AUTO_INCREMENT,\n" +
+				"  `name` VARCHAR(64) NOT NULL,\n" +
+				"  `age` INT NOT NULL DEFAULT 0,\n" +
+				"  `email` VARCHAR(128) NULL,\n" +
+				"  `nick` VARCHAR(255) NULL,\n" +
+				"  `balance` DECIMAL(10,2) NOT NULL,\n" +
+				"  `created_at` DATETIME NOT NULL,\n" +
+				"  PRIMARY KEY (`id`),\n" +
+				"  KEY `idx_name_age` (`name`, `age`),\n" +
+				"  UNIQUE KEY `uk_account_email` (`email`)\n" +
+				");"))
+
+			script, err = orm.CreateTableSQL(&Account{}, orm.SQLite)
+			Expect(err).Should(Succeed())
+			Expect(script).Should(ContainSubstring(`"id" INTEGER PRIMARY KEY AUTOINCREMENT`))
+			Expect(script).Should(ContainSubstring(`CREATE UNIQUE INDEX IF NOT EXISTS "uk_account_email" ON "account" ("email")`))
+
+			script, err = orm.CreateTableSQL(&Account{}, orm.PostgreSQL)
+			Expect(err).Should(Succeed())
+			Expect(script).Should(ContainSubstring(`"id" BIGSERIAL NOT NULL`))
+		})
+
+		It("should reject unknown options", func() {
+			type BadModel struct {
+				ID int `db:"id" orm:"primary"`
+			}
+
+			_, err := orm.CreateTableSQL(&BadModel{}, orm.MySQL)
+			Expect(err).ShouldNot(Succeed())
+		})
+	})
+
+	Context("diff table", func() {
+		BeforeEach(requireMySQL)
+
+		It("should be succeed", func() {
+			drifts, err := orm.DiffTable(context.TODO(), db, &SampleRow{}, orm.MySQL)
+			Expect(err).Should(Succeed())
+			Expect(drifts).Should(BeEmpty())
+
+			script, err := orm.CreateTableSQL(&Account{}, orm.MySQL)
+			Expect(err).Should(Succeed())
+
+			// 重复执行不报错
+			for i := 0; i < 2; i++ {
+				for _, stmt := range orm.SplitStatements(script) {
+					_, err = db.Exec(stmt)
+					Expect(err).Should(Succeed())
+				}
+			}
+
+			_, err = db.Exec("ALTER TABLE account MODIFY COLUMN name VARCHAR(32) NULL, DROP COLUMN nick, ADD COLUMN extra INT")
+			Expect(err).Should(Succeed())
+
+			drifts, err = orm.DiffTable(context.TODO(), db, &Account{}, orm.MySQL)
+			Expect(err).Should(Succeed())
+
+			kinds := map[string][]orm.DriftKind{}
+			for _, d := range drifts {
+				kinds[d.Column] = append(kinds[d.Column], d.Kind)
+			}
+
+			Expect(kinds["name"]).Should(ConsistOf(orm.DriftType, orm.DriftNullable))
+			Expect(kinds["nick"]).Should(ConsistOf(orm.DriftMissingColumn))
+			Expect(kinds["extra"]).Should(ConsistOf(orm.DriftExtraColumn))
+		})
+	})
+})
@@ -0,0 +1,88 @@
+package orm
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Dialect 数据库方言，决定占位符及标识符的书写方式
+type Dialect int
+
+const (
+	MySQL      Dialect = iota // 占位符 ?，标识符 `name`
+	PostgreSQL                // 占位符 $1, $2 ...，标识符 "name"
+	SQLite                    // 占位符 ?，标识符 "name"
+)
+
+// String 方言名称
+func (d Dialect) String() string {
+	switch d {
+	case MySQL:
+		return "mysql"
+	case PostgreSQL:
+		return "postgres"
+	case SQLite:
+		return "sqlite"
+	}
+
+	return "unknown"
+}
+
+// Placeholder 返回第n个（从1开始）参数的占位符
+func (d Dialect) Placeholder(n int) string {
+	if d == PostgreSQL {
+		return "$" + strconv.Itoa(n)
+	}
+
+	return "?"
+}
+
+// MaxPlaceholders 单条语句的占位符数量上限；SQLite 3.32 之前的默认上限为999，按较小值限制
+func (d Dialect) MaxPlaceholders() int {
+	if d == SQLite {
+		return 999
+	}
+
+	return 65535
+}
+
+// Quote 对标识符加引号
+func (d Dialect) Quote(ident string) string {
+	if d == MySQL {
+		return "`" + strings.Replace(ident, "`", "``", -1) + "`"
+	}
+
+	return `"` + strings.Replace(ident, `"`, `""`, -1) + `"`
+}
+
+// Rebind 将语句中的 ? 占位符转换为当前方言的占位符，引号内的 ? 不做转换
+func (d Dialect) Rebind(query string) string {
+	if d.Placeholder(1) == "?" {
+		return query
+	}
+
+	var b strings.Builder
+	b.Grow(len(query) + 8)
+
+	n := 0
+	var quote byte
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '?':
+			n++
+			b.WriteString(d.Placeholder(n))
+			continue
+		}
+
+		b.WriteByte(c)
+	}
+
+	return b.String()
+}
@@ -0,0 +1,479 @@
+package orm
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"reflect"
+)
+
+/*
+ * 在驱动层包装连接，使 *sql.Rows、*sql.Tx 的生命周期及事务中的语句可以被感知：
+ * 通过 withRelease 传入的回调在结果集关闭、事务提交或回滚时调用；设置了 stmtHook 时每条语句的执行都经过钩子。
+ * 包装后的连接保留驱动实现的可选接口，驱动未实现时与 database/sql 的默认行为一致
+ */
+
+// stmtHook 在驱动层记录语句的执行，f执行语句
+type stmtHook interface {
+	do(ctx context.Context, op, query string, args []interface{}, f func(ctx context.Context, e *QueryEvent) error) error
+}
+
+type releaseKey struct{}
+
+// withRelease 返回携带release的context，使用该context创建的结果集关闭或事务结束时调用release
+func withRelease(ctx context.Context, release func()) context.Context {
+	return context.WithValue(ctx, releaseKey{}, release)
+}
+
+func releaseFrom(ctx context.Context) func() {
+	release, _ := ctx.Value(releaseKey{}).(func())
+	return release
+}
+
+// openConnector 按驱动名及DSN创建包装后的 driver.Connector，驱动需要已经注册；hook可以为nil
+func openConnector(driverName, dsn string, hook stmtHook) (driver.Connector, error) {
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	// sql.Open 不会建立连接，只用于取得注册的驱动
+	drv := db.Driver()
+	db.Close()
+
+	if dc, ok := drv.(driver.DriverContext); ok {
+		connector, err := dc.OpenConnector(dsn)
+		if err != nil {
+			return nil, err
+		}
+		return &wrappedConnector{Connector: connector, hook: hook}, nil
+	}
+
+	return &wrappedConnector{Connector: &dsnConnector{dsn: dsn, driver: drv}, hook: hook}, nil
+}
+
+// dsnConnector 驱动未实现 driver.DriverContext 时按DSN打开连接
+type dsnConnector struct {
+	dsn    string
+	driver driver.Driver
+}
+
+func (c *dsnConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	return c.driver.Open(c.dsn)
+}
+
+func (c *dsnConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+type wrappedConnector struct {
+	driver.Connector
+	hook stmtHook
+}
+
+func (c *wrappedConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &wrappedConn{Conn: conn, hook: c.hook}, nil
+}
+
+type wrappedConn struct {
+	driver.Conn
+	hook stmtHook
+}
+
+func (c *wrappedConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *wrappedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	stmt, err := c.prepare(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	return &wrappedStmt{Stmt: stmt, conn: c, query: query}, nil
+}
+
+func (c *wrappedConn) prepare(ctx context.Context, query string) (driver.Stmt, error) {
+	if p, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		return p.PrepareContext(ctx, query)
+	}
+
+	return c.Conn.Prepare(query)
+}
+
+func (c *wrappedConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *wrappedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	var tx driver.Tx
+	var err error
+	if b, ok := c.Conn.(driver.ConnBeginTx); ok {
+		tx, err = b.BeginTx(ctx, opts)
+	} else {
+		if opts.Isolation != driver.IsolationLevel(sql.LevelDefault) {
+			return nil, errors.New("sql: driver does not support non-default isolation level")
+		}
+		if opts.ReadOnly {
+			return nil, errors.New("sql: driver does not support read-only transactions")
+		}
+		tx, err = c.Conn.Begin()
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &wrappedTx{Tx: tx, release: releaseFrom(ctx)}, nil
+}
+
+// QueryContext 驱动不支持直接执行时返回 driver.ErrSkip，由 database/sql 改为预处理后执行；
+// 设置了钩子时在连接上预处理后执行，保证一条语句只经过一次钩子
+func (c *wrappedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if c.hook == nil {
+		rows, err := c.query(ctx, query, args)
+		if err != nil {
+			return nil, err
+		}
+		return wrapRows(ctx, rows), nil
+	}
+
+	var rows driver.Rows
+	err := c.hook.do(ctx, OpQuery, query, eventArgs(args), func(ctx context.Context, e *QueryEvent) error {
+		var err error
+		if rows, err = c.query(ctx, query, args); err == driver.ErrSkip {
+			rows, err = c.prepareQuery(ctx, query, args)
+		}
+		e.RowsAffected = -1
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return wrapRows(ctx, rows), nil
+}
+
+func (c *wrappedConn) query(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if q, ok := c.Conn.(driver.QueryerContext); ok {
+		return q.QueryContext(ctx, query, args)
+	}
+
+	return nil, driver.ErrSkip
+}
+
+// prepareQuery 预处理后执行，语句在结果集关闭时关闭
+func (c *wrappedConn) prepareQuery(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	stmt, err := c.prepare(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := stmtQuery(ctx, stmt, args)
+	if err != nil {
+		stmt.Close()
+		return nil, err
+	}
+
+	return &stmtRows{Rows: rows, stmt: stmt}, nil
+}
+
+// ExecContext 驱动不支持直接执行时返回 driver.ErrSkip，由 database/sql 改为预处理后执行；
+// 设置了钩子时在连接上预处理后执行，保证一条语句只经过一次钩子
+func (c *wrappedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if c.hook == nil {
+		return c.exec(ctx, query, args)
+	}
+
+	var result driver.Result
+	err := c.hook.do(ctx, OpExec, query, eventArgs(args), func(ctx context.Context, e *QueryEvent) error {
+		var err error
+		if result, err = c.exec(ctx, query, args); err == driver.ErrSkip {
+			result, err = c.prepareExec(ctx, query, args)
+		}
+		if err == nil {
+			e.RowsAffected, _ = result.RowsAffected()
+		}
+		return err
+	})
+
+	return result, err
+}
+
+func (c *wrappedConn) exec(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if e, ok := c.Conn.(driver.ExecerContext); ok {
+		return e.ExecContext(ctx, query, args)
+	}
+
+	return nil, driver.ErrSkip
+}
+
+func (c *wrappedConn) prepareExec(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	stmt, err := c.prepare(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	return stmtExec(ctx, stmt, args)
+}
+
+func (c *wrappedConn) Ping(ctx context.Context) error {
+	if p, ok := c.Conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+
+	return nil
+}
+
+func (c *wrappedConn) ResetSession(ctx context.Context) error {
+	if r, ok := c.Conn.(driver.SessionResetter); ok {
+		return r.ResetSession(ctx)
+	}
+
+	return nil
+}
+
+func (c *wrappedConn) IsValid() bool {
+	if v, ok := c.Conn.(driver.Validator); ok {
+		return v.IsValid()
+	}
+
+	return true
+}
+
+func (c *wrappedConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if checker, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(nv)
+	}
+
+	return driver.ErrSkip
+}
+
+type wrappedStmt struct {
+	driver.Stmt
+	conn  *wrappedConn
+	query string
+}
+
+func (s *wrappedStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	var rows driver.Rows
+	var err error
+	if s.conn.hook == nil {
+		rows, err = stmtQuery(ctx, s.Stmt, args)
+	} else {
+		err = s.conn.hook.do(ctx, OpQuery, s.query, eventArgs(args), func(ctx context.Context, e *QueryEvent) error {
+			var err error
+			rows, err = stmtQuery(ctx, s.Stmt, args)
+			e.RowsAffected = -1
+			return err
+		})
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return wrapRows(ctx, rows), nil
+}
+
+func (s *wrappedStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	if s.conn.hook == nil {
+		return stmtExec(ctx, s.Stmt, args)
+	}
+
+	var result driver.Result
+	err := s.conn.hook.do(ctx, OpExec, s.query, eventArgs(args), func(ctx context.Context, e *QueryEvent) error {
+		var err error
+		if result, err = stmtExec(ctx, s.Stmt, args); err == nil {
+			e.RowsAffected, _ = result.RowsAffected()
+		}
+		return err
+	})
+
+	return result, err
+}
+
+// CheckNamedValue database/sql 优先使用语句的 NamedValueChecker，语句未实现时交给连接
+func (s *wrappedStmt) CheckNamedValue(nv *driver.NamedValue) error {
+	if checker, ok := s.Stmt.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(nv)
+	}
+
+	return s.conn.CheckNamedValue(nv)
+}
+
+func stmtQuery(ctx context.Context, stmt driver.Stmt, args []driver.NamedValue) (driver.Rows, error) {
+	if q, ok := stmt.(driver.StmtQueryContext); ok {
+		return q.QueryContext(ctx, args)
+	}
+
+	values, err := namedValues(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+
+	return stmt.Query(values)
+}
+
+func stmtExec(ctx context.Context, stmt driver.Stmt, args []driver.NamedValue) (driver.Result, error) {
+	if e, ok := stmt.(driver.StmtExecContext); ok {
+		return e.ExecContext(ctx, args)
+	}
+
+	values, err := namedValues(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+
+	return stmt.Exec(values)
+}
+
+// eventArgs 取出参数值，用于 QueryEvent
+func eventArgs(args []driver.NamedValue) []interface{} {
+	values := make([]interface{}, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+
+	return values
+}
+
+// namedValues 转换为旧版接口的参数，旧版接口不支持命名参数
+func namedValues(ctx context.Context, args []driver.NamedValue) ([]driver.Value, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		if arg.Name != "" {
+			return nil, errors.New("sql: driver does not support the use of Named Parameters")
+		}
+		values[i] = arg.Value
+	}
+
+	return values, nil
+}
+
+type wrappedTx struct {
+	driver.Tx
+	release func()
+}
+
+func (t *wrappedTx) Commit() error {
+	defer t.done()
+	return t.Tx.Commit()
+}
+
+func (t *wrappedTx) Rollback() error {
+	defer t.done()
+	return t.Tx.Rollback()
+}
+
+func (t *wrappedTx) done() {
+	if t.release != nil {
+		t.release()
+	}
+}
+
+// wrapRows 包装结果集，context中没有回调时返回原始的结果集
+func wrapRows(ctx context.Context, rows driver.Rows) driver.Rows {
+	release := releaseFrom(ctx)
+	if release == nil {
+		return rows
+	}
+
+	return &wrappedRows{Rows: rows, release: release}
+}
+
+var anyType = reflect.TypeOf(new(interface{})).Elem()
+
+// stmtRows 关闭结果集时同时关闭预处理的语句
+type stmtRows struct {
+	driver.Rows
+	stmt driver.Stmt
+}
+
+func (r *stmtRows) Close() error {
+	err := r.Rows.Close()
+	if closeErr := r.stmt.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+type wrappedRows struct {
+	driver.Rows
+	release func()
+}
+
+func (r *wrappedRows) Close() error {
+	defer r.release()
+	return r.Rows.Close()
+}
+
+func (r *wrappedRows) HasNextResultSet() bool {
+	if n, ok := r.Rows.(driver.RowsNextResultSet); ok {
+		return n.HasNextResultSet()
+	}
+
+	return false
+}
+
+func (r *wrappedRows) NextResultSet() error {
+	if n, ok := r.Rows.(driver.RowsNextResultSet); ok {
+		return n.NextResultSet()
+	}
+
+	return io.EOF
+}
+
+func (r *wrappedRows) ColumnTypeScanType(index int) reflect.Type {
+	if t, ok := r.Rows.(driver.RowsColumnTypeScanType); ok {
+		return t.ColumnTypeScanType(index)
+	}
+
+	return anyType
+}
+
+func (r *wrappedRows) ColumnTypeDatabaseTypeName(index int) string {
+	if t, ok := r.Rows.(driver.RowsColumnTypeDatabaseTypeName); ok {
+		return t.ColumnTypeDatabaseTypeName(index)
+	}
+
+	return ""
+}
+
+func (r *wrappedRows) ColumnTypeLength(index int) (int64, bool) {
+	if t, ok := r.Rows.(driver.RowsColumnTypeLength); ok {
+		return t.ColumnTypeLength(index)
+	}
+
+	return 0, false
+}
+
+func (r *wrappedRows) ColumnTypeNullable(index int) (bool, bool) {
+	if t, ok := r.Rows.(driver.RowsColumnTypeNullable); ok {
+		return t.ColumnTypeNullable(index)
+	}
+
+	return false, false
+}
+
+func (r *wrappedRows) ColumnTypePrecisionScale(index int) (int64, int64, bool) {
+	if t, ok := r.Rows.(driver.RowsColumnTypePrecisionScale); ok {
+		return t.ColumnTypePrecisionScale(index)
+	}
+
+	return 0, 0, false
+}
@@ -0,0 +1,278 @@
+package orm
+
+import (
+	"bufio"
+	"context"
+	"database/sql/driver"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"time"
+
+	"util/primitive"
+)
+
+/*
+ * 将查询结果流式导出为 CSV 或 JSON Lines，逐行读取、逐行写入，内存占用与结果集大小无关；
+ * 列名取自数据对象的"db"描述符，成员顺序需要与查询的列顺序一致
+ *  e.g
+ *  f, _ := os.Create("sample.csv.gz")
+ *  defer f.Close()
+ *
+ *  opts := orm.ExportOpts{}
+ *  opts.SetGzip()
+ *  n, err := orm.ExportCSV(ctx, db, f, opts, "SELECT user_id, user_name, city FROM sample", &UserInfo{})
+ */
+
+const defaultTimeLayout = time.RFC3339
+
+// ExportOpts 导出选项
+type ExportOpts struct {
+	gzip       bool    // 使用gzip压缩
+	noHeader   bool    // CSV 不写入列名
+	comma      *rune   // CSV 分隔符
+	timeLayout *string // 时间格式
+}
+
+// SetGzip 输出使用gzip压缩
+func (opts *ExportOpts) SetGzip() {
+	opts.gzip = true
+}
+
+// SetNoHeader CSV 第一行不写入列名
+func (opts *ExportOpts) SetNoHeader() {
+	opts.noHeader = true
+}
+
+// SetComma 设置 CSV 分隔符，默认为逗号
+func (opts *ExportOpts) SetComma(comma rune) {
+	opts.comma = &comma
+}
+
+// SetTimeLayout 设置时间格式，默认为 time.RFC3339
+func (opts *ExportOpts) SetTimeLayout(layout string) {
+	opts.timeLayout = &layout
+}
+
+// rowWriter 按格式写入一行数据
+type rowWriter interface {
+	writeHeader(cols []string) error
+	writeRow(cols []string, values []interface{}) error
+	flush() error
+}
+
+// ExportCSV 执行sql语句并将结果以 CSV 格式写入w，modelPtr为数据对象的指针；返回导出的行数。
+// 空值（nil 指针、无效的 sql.NullXXX）导出为空字符串
+func ExportCSV(ctx context.Context, db Executor, w io.Writer, opts ExportOpts, sql string, modelPtr interface{}, args ...interface{}) (int64, error) {
+	return export(ctx, db, w, opts, func(w io.Writer) rowWriter {
+		cw := csv.NewWriter(w)
+		if opts.comma != nil {
+			cw.Comma = *opts.comma
+		}
+		return &csvWriter{w: cw, layout: opts.layout(), noHeader: opts.noHeader}
+	}, sql, modelPtr, args...)
+}
+
+// ExportJSONLines 执行sql语句并将结果以 JSON Lines 格式写入w，每行为一个以列名为键的 JSON 对象，
+// 键的顺序与列顺序一致；返回导出的行数。空值导出为 null
+func ExportJSONLines(ctx context.Context, db Executor, w io.Writer, opts ExportOpts, sql string, modelPtr interface{}, args ...interface{}) (int64, error) {
+	return export(ctx, db, w, opts, func(w io.Writer) rowWriter {
+		return &jsonLinesWriter{w: bufio.NewWriter(w), layout: opts.layout()}
+	}, sql, modelPtr, args...)
+}
+
+func (opts *ExportOpts) layout() string {
+	if opts.timeLayout != nil {
+		return *opts.timeLayout
+	}
+
+	return defaultTimeLayout
+}
+
+func export(ctx context.Context, db Executor, w io.Writer, opts ExportOpts, newWriter func(w io.Writer) rowWriter, sql string, modelPtr interface{}, args ...interface{}) (n int64, err error) {
+	cols, err := GetColNames(modelPtr, defaultTagName)
+	if err != nil {
+		return 0, err
+	}
+
+	// 查询成功后再写入任何内容，查询失败时w保持为空
+	rows, err := QueryRows(ctx, db, sql, args...)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	names, err := rows.Columns()
+	if err != nil {
+		return 0, err
+	}
+
+	if len(names) != len(cols) {
+		return 0, fmt.Errorf("query returns %d columns, model has %d", len(names), len(cols))
+	}
+
+	if opts.gzip {
+		gz := primitive.NewGzipWriter(w)
+		defer func() {
+			if closeErr := gz.Close(); closeErr != nil && err == nil {
+				err = closeErr
+			}
+		}()
+		w = gz
+	}
+
+	rw := newWriter(w)
+	if err := rw.writeHeader(cols); err != nil {
+		return 0, err
+	}
+
+	// 所有行共用同一个数据对象，避免逐行分配
+	record := reflect.New(reflect.TypeOf(modelPtr).Elem())
+	values := make([]interface{}, len(cols))
+	for i := 0; rows.Next(); i++ {
+		record.Elem().Set(reflect.Zero(record.Elem().Type()))
+		if err := rows.Scan(record.Interface()); err != nil {
+			return n, &ScanError{Row: i, Column: scanErrColumn(err, names), Err: err}
+		}
+
+		values = exportValues(record.Elem(), values[:0])
+		if err := rw.writeRow(cols, values); err != nil {
+			return n, err
+		}
+		n++
+	}
+
+	if err := rows.Err(); err != nil {
+		return n, err
+	}
+
+	if err := rows.Close(); err != nil {
+		return n, err
+	}
+
+	return n, rw.flush()
+}
+
+// exportValues 取出结构体中对应列的成员值，nil 指针、driver.Valuer 均转换为基础类型
+func exportValues(v reflect.Value, values []interface{}) []interface{} {
+	for i := 0; i < v.NumField(); i++ {
+		if isAssociation(v.Type().Field(i)) {
+			continue
+		}
+
+		values = append(values, exportValue(v.Field(i)))
+	}
+
+	return values
+}
+
+func exportValue(field reflect.Value) interface{} {
+	value := field.Interface()
+	if valuer, ok := value.(driver.Valuer); ok {
+		if v, err := valuer.Value(); err == nil {
+			return v
+		}
+		return nil
+	}
+
+	if field.Kind() == reflect.Ptr {
+		if field.IsNil() {
+			return nil
+		}
+		return field.Elem().Interface()
+	}
+
+	return value
+}
+
+func formatTime(v interface{}, layout string) interface{} {
+	if t, ok := v.(time.Time); ok {
+		return t.Format(layout)
+	}
+
+	return v
+}
+
+type csvWriter struct {
+	w        *csv.Writer
+	layout   string
+	noHeader bool
+	record   []string
+}
+
+func (c *csvWriter) writeHeader(cols []string) error {
+	if c.noHeader {
+		return nil
+	}
+
+	return c.w.Write(cols)
+}
+
+func (c *csvWriter) writeRow(cols []string, values []interface{}) error {
+	c.record = c.record[:0]
+	for _, v := range values {
+		switch value := formatTime(v, c.layout).(type) {
+		case nil:
+			c.record = append(c.record, "")
+		case []byte:
+			c.record = append(c.record, string(value))
+		case string:
+			c.record = append(c.record, value)
+		default:
+			c.record = append(c.record, fmt.Sprint(value))
+		}
+	}
+
+	return c.w.Write(c.record)
+}
+
+func (c *csvWriter) flush() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+type jsonLinesWriter struct {
+	w      *bufio.Writer
+	layout string
+}
+
+func (j *jsonLinesWriter) writeHeader(cols []string) error {
+	return nil
+}
+
+func (j *jsonLinesWriter) writeRow(cols []string, values []interface{}) error {
+	j.w.WriteByte('{')
+	for i, v := range values {
+		if i > 0 {
+			j.w.WriteByte(',')
+		}
+
+		key, err := json.Marshal(cols[i])
+		if err != nil {
+			return err
+		}
+
+		v = formatTime(v, j.layout)
+		if b, ok := v.([]byte); ok {
+			v = string(b)
+		}
+
+		value, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Errorf("column %s: %w", cols[i], err)
+		}
+
+		j.w.Write(key)
+		j.w.WriteByte(':')
+		j.w.Write(value)
+	}
+
+	_, err := j.w.WriteString("}\n")
+	return err
+}
+
+func (j *jsonLinesWriter) flush() error {
+	return j.w.Flush()
+}
@@ -0,0 +1,97 @@
+package orm_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"util/orm"
+	"util/orm/ormtest"
+	"util/primitive"
+)
+
+var _ = Describe("Export", func() {
+
+	BeforeEach(requireMySQL)
+
+	const script = "SELECT user_id, user_name, city FROM sample WHERE city = ? ORDER BY user_id"
+
+	Context("csv", func() {
+		It("should be succeed", func() {
+			var buff bytes.Buffer
+			n, err := orm.ExportCSV(context.TODO(), db, &buff, orm.ExportOpts{}, script, &UserInfo{}, "beijing")
+			Expect(err).Should(Succeed())
+			Expect(n == 25).Should(BeTrue())
+
+			lines, err := csv.NewReader(&buff).ReadAll()
+			Expect(err).Should(Succeed())
+			Expect(len(lines) == 26).Should(BeTrue())
+			Expect(strings.Join(lines[0], ",") == "user_id,user_name,city").Should(BeTrue())
+			Expect(strings.Join(lines[1], ",") == "1000,user_0,beijing").Should(BeTrue())
+		})
+
+		It("should be compressed", func() {
+			opts := orm.ExportOpts{}
+			opts.SetGzip()
+			opts.SetNoHeader()
+			opts.SetComma('\t')
+
+			var buff bytes.Buffer
+			n, err := orm.ExportCSV(context.TODO(), db, &buff, opts, script, &UserInfo{}, "shanghai")
+			Expect(err).Should(Succeed())
+			Expect(n == 25).Should(BeTrue())
+
+			data, err := primitive.Gunzip(buff.Bytes())
+			Expect(err).Should(Succeed())
+			Expect(strings.HasPrefix(string(data), "1001\tuser_1\tshanghai\n")).Should(BeTrue())
+		})
+	})
+
+	Context("json lines", func() {
+		It("should be succeed", func() {
+			var buff bytes.Buffer
+			n, err := orm.ExportJSONLines(context.TODO(), db, &buff, orm.ExportOpts{}, script, &UserInfo{}, "chengdu")
+			Expect(err).Should(Succeed())
+			Expect(n == 25).Should(BeTrue())
+
+			scanner := bufio.NewScanner(&buff)
+			Expect(scanner.Scan()).Should(BeTrue())
+			Expect(scanner.Text() == `{"user_id":1002,"user_name":"user_2","city":"chengdu"}`).Should(BeTrue())
+
+			var last map[string]interface{}
+			for scanner.Scan() {
+				Expect(json.Unmarshal(scanner.Bytes(), &last)).Should(Succeed())
+			}
+			Expect(last["user_id"] == float64(1098)).Should(BeTrue())
+		})
+	})
+})
+
+var _ = Describe("Export without database", func() {
+	It("should write nothing when query failed", func() {
+		mockDB, mock := ormtest.New()
+		defer mockDB.Close()
+
+		mock.ExpectQuery("SELECT user_id").WillReturnError(errors.New("table not exist"))
+		mock.ExpectQuery("SELECT user_id").WillReturnRows(ormtest.NewRows("user_id", "user_name"))
+
+		opts := orm.ExportOpts{}
+		opts.SetGzip()
+
+		var buff bytes.Buffer
+		_, err := orm.ExportCSV(context.TODO(), mockDB, &buff, opts, "SELECT user_id, user_name, city FROM sample", &UserInfo{})
+		Expect(err).ShouldNot(Succeed())
+		Expect(buff.Len() == 0).Should(BeTrue())
+
+		_, err = orm.ExportCSV(context.TODO(), mockDB, &buff, opts, "SELECT user_id, user_name FROM sample", &UserInfo{})
+		Expect(err).ShouldNot(Succeed())
+		Expect(buff.Len() == 0).Should(BeTrue())
+		Expect(mock.ExpectationsWereMet()).Should(Succeed())
+	})
+})
@@ -0,0 +1,355 @@
+package orm
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+/*
+ * InstrumentedDB 记录每条语句的执行耗时、影响行数及错误，并回调注册的钩子；可以代替 *sql.DB 传给本包的所有函数。
+ *
+ * OpenInstrumented 在驱动层记录，事务中的语句同样经过钩子并计入统计，包括 WithTx 回调、BatchInsert 及 Migrator 执行的语句；
+ * NewInstrumentedDB 包装已有的 Executor，只记录直接通过它执行的语句，BeginTx 返回的事务不做记录
+ *  e.g
+ *  idb, err := orm.OpenInstrumented("mysql", dsn)
+ *  defer idb.Close()
+ *
+ *  idb.AddHook(orm.NewSlowQueryLogger(200, nil))
+ *  records, err := orm.Query(ctx, idb, sql, &UserInfo{})
+ *  err = orm.WithTx(ctx, idb, nil, func(tx *sql.Tx) error {...})
+ *  stats := idb.Stats()
+ */
+
+const (
+	OpQuery = "query"
+	OpExec  = "exec"
+
+	defaultSlowThresholdMS = 1000
+	defaultMaxStatements   = 1000
+	otherStatements        = "other" // 超出统计上限的语句统一归入该项
+)
+
+// QueryEvent 一次语句执行的信息
+type QueryEvent struct {
+	Op           string        // OpQuery 或 OpExec
+	SQL          string        // sql语句
+	Args         []interface{} // 参数
+	Start        time.Time     // 开始时间
+	Duration     time.Duration // 耗时，Before 中为0；query 语句只统计到返回结果集为止，不包含读取数据的时间
+	RowsAffected int64         // exec 语句影响的行数，query 语句为-1
+	Err          error         // 执行错误
+}
+
+// Hook 语句执行钩子，Before 返回的 context 会传给数据库及 After
+type Hook interface {
+	Before(ctx context.Context, e *QueryEvent) context.Context
+	After(ctx context.Context, e *QueryEvent)
+}
+
+// StatementStatus 单条语句的统计信息
+type StatementStatus struct {
+	Count   uint64  `json:"count"`    // 执行次数
+	Errors  uint64  `json:"errors"`   // 错误次数
+	Slow    uint64  `json:"slow"`     // 慢查询次数
+	TotalMS float64 `json:"total_ms"` // 总耗时
+	MaxMS   float64 `json:"max_ms"`   // 最大耗时
+}
+
+// InstrumentStatus 统计信息，可以与 MaxClientsStatus 一同输出
+type InstrumentStatus struct {
+	Queries     uint64                      `json:"queries"`      // query 语句执行次数
+	Execs       uint64                      `json:"execs"`        // exec 语句执行次数
+	Errors      uint64                      `json:"errors"`       // 错误次数
+	SlowQueries uint64                      `json:"slow_queries"` // 慢查询次数
+	Statements  map[string]*StatementStatus `json:"statements"`   // 按语句统计
+}
+
+// InstrumentOpts 统计选项
+type InstrumentOpts struct {
+	slowThresholdMS *int64 // 慢查询阈值(毫秒)
+	maxStatements   *int   // 按语句统计的条数上限
+}
+
+// SetSlowThreshold 设置慢查询阈值(毫秒)，默认1000
+func (opts *InstrumentOpts) SetSlowThreshold(ms int64) {
+	opts.slowThresholdMS = &ms
+}
+
+// SetMaxStatements 设置按语句统计的条数上限，超出的语句统一归入"other"，默认1000
+func (opts *InstrumentOpts) SetMaxStatements(n int) {
+	opts.maxStatements = &n
+}
+
+// InstrumentedDB 带统计及钩子的 Executor，协程安全
+type InstrumentedDB struct {
+	db              Executor
+	sqlDB           *sql.DB // OpenInstrumented 打开的连接池，由驱动层记录
+	slowThresholdMS int64
+	maxStatements   int
+
+	hooks      []Hook
+	statements map[string]*StatementStatus
+	mutex      sync.RWMutex
+
+	queries     uint64
+	execs       uint64
+	errors      uint64
+	slowQueries uint64
+}
+
+// NewInstrumentedDB 包装db，db通常为 *sql.DB
+func NewInstrumentedDB(db Executor, opts ...InstrumentOpts) *InstrumentedDB {
+	idb := &InstrumentedDB{
+		db:              db,
+		slowThresholdMS: defaultSlowThresholdMS,
+		maxStatements:   defaultMaxStatements,
+		statements:      make(map[string]*StatementStatus),
+	}
+
+	if len(opts) > 0 {
+		opt := opts[0]
+
+		if opt.slowThresholdMS != nil {
+			idb.slowThresholdMS = *opt.slowThresholdMS
+		}
+
+		if opt.maxStatements != nil {
+			idb.maxStatements = *opt.maxStatements
+		}
+	}
+
+	return idb
+}
+
+// OpenInstrumented 打开在驱动层记录语句的连接池，driverName为已注册的驱动名；不再使用时需要调用Close
+func OpenInstrumented(driverName, dsn string, opts ...InstrumentOpts) (*InstrumentedDB, error) {
+	idb := NewInstrumentedDB(nil, opts...)
+
+	connector, err := openConnector(driverName, dsn, idb)
+	if err != nil {
+		return nil, err
+	}
+
+	idb.sqlDB = sql.OpenDB(connector)
+	idb.db = idb.sqlDB
+	return idb, nil
+}
+
+// DB 返回 OpenInstrumented 打开的 *sql.DB，通过它执行的语句同样会被记录；NewInstrumentedDB 创建时返回nil
+func (idb *InstrumentedDB) DB() *sql.DB {
+	return idb.sqlDB
+}
+
+// Close 关闭 OpenInstrumented 打开的连接池，NewInstrumentedDB 创建时不做任何操作
+func (idb *InstrumentedDB) Close() error {
+	if idb.sqlDB == nil {
+		return nil
+	}
+
+	return idb.sqlDB.Close()
+}
+
+// AddHook 注册钩子，按注册顺序调用 Before，按相反顺序调用 After
+func (idb *InstrumentedDB) AddHook(h Hook) {
+	idb.mutex.Lock()
+	defer idb.mutex.Unlock()
+
+	idb.hooks = append(idb.hooks, h)
+}
+
+// QueryContext 实现 Executor
+func (idb *InstrumentedDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	if idb.sqlDB != nil {
+		return idb.sqlDB.QueryContext(ctx, query, args...)
+	}
+
+	var rows *sql.Rows
+	err := idb.do(ctx, OpQuery, query, args, func(ctx context.Context, e *QueryEvent) error {
+		var err error
+		rows, err = idb.db.QueryContext(ctx, query, args...)
+		e.RowsAffected = -1
+		return err
+	})
+
+	return rows, err
+}
+
+// ExecContext 实现 Executor
+func (idb *InstrumentedDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	if idb.sqlDB != nil {
+		return idb.sqlDB.ExecContext(ctx, query, args...)
+	}
+
+	var result sql.Result
+	err := idb.do(ctx, OpExec, query, args, func(ctx context.Context, e *QueryEvent) error {
+		var err error
+		if result, err = idb.db.ExecContext(ctx, query, args...); err == nil {
+			e.RowsAffected, _ = result.RowsAffected()
+		}
+		return err
+	})
+
+	return result, err
+}
+
+// BeginTx 开启事务，被包装的db需要支持事务；OpenInstrumented 打开时事务中的语句同样会被记录，
+// NewInstrumentedDB 创建时返回原始的 *sql.Tx，事务中的语句不经过钩子，也不做统计
+func (idb *InstrumentedDB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	beginner, ok := idb.db.(TxBeginner)
+	if !ok {
+		return nil, errors.New("executor does not support transaction")
+	}
+
+	return beginner.BeginTx(ctx, opts)
+}
+
+// Stats 统计信息
+func (idb *InstrumentedDB) Stats() *InstrumentStatus {
+	idb.mutex.RLock()
+	defer idb.mutex.RUnlock()
+
+	stat := &InstrumentStatus{
+		Queries:     atomic.LoadUint64(&idb.queries),
+		Execs:       atomic.LoadUint64(&idb.execs),
+		Errors:      atomic.LoadUint64(&idb.errors),
+		SlowQueries: atomic.LoadUint64(&idb.slowQueries),
+		Statements:  make(map[string]*StatementStatus, len(idb.statements)),
+	}
+
+	for query, s := range idb.statements {
+		cp := *s
+		stat.Statements[query] = &cp
+	}
+
+	return stat
+}
+
+func (idb *InstrumentedDB) do(ctx context.Context, op, query string, args []interface{}, f func(ctx context.Context, e *QueryEvent) error) error {
+	idb.mutex.RLock()
+	hooks := idb.hooks
+	idb.mutex.RUnlock()
+
+	e := &QueryEvent{Op: op, SQL: query, Args: args, Start: time.Now()}
+	for _, h := range hooks {
+		ctx = h.Before(ctx, e)
+	}
+
+	e.Err = f(ctx, e)
+	e.Duration = time.Since(e.Start)
+
+	idb.record(e)
+	for i := len(hooks) - 1; i >= 0; i-- {
+		hooks[i].After(ctx, e)
+	}
+
+	return e.Err
+}
+
+func (idb *InstrumentedDB) record(e *QueryEvent) {
+	if e.Op == OpQuery {
+		atomic.AddUint64(&idb.queries, 1)
+	} else {
+		atomic.AddUint64(&idb.execs, 1)
+	}
+
+	slow := e.Duration >= time.Duration(idb.slowThresholdMS)*time.Millisecond
+	if slow {
+		atomic.AddUint64(&idb.slowQueries, 1)
+	}
+
+	if e.Err != nil {
+		atomic.AddUint64(&idb.errors, 1)
+	}
+
+	key := strings.Join(strings.Fields(e.SQL), " ")
+	ms := float64(e.Duration) / float64(time.Millisecond)
+
+	idb.mutex.Lock()
+	defer idb.mutex.Unlock()
+
+	s, ok := idb.statements[key]
+	if !ok {
+		if len(idb.statements) >= idb.maxStatements {
+			key = otherStatements
+		}
+
+		if s, ok = idb.statements[key]; !ok {
+			s = &StatementStatus{}
+			idb.statements[key] = s
+		}
+	}
+
+	s.Count++
+	s.TotalMS += ms
+	if ms > s.MaxMS {
+		s.MaxMS = ms
+	}
+
+	if slow {
+		s.Slow++
+	}
+
+	if e.Err != nil {
+		s.Errors++
+	}
+}
+
+// SlowLogOpts 慢查询日志选项
+type SlowLogOpts struct {
+	redactArgs bool // 不输出参数值
+}
+
+// SetRedactArgs 日志中只输出参数个数，不输出参数值，避免密码、手机号等敏感数据写入日志
+func (opts *SlowLogOpts) SetRedactArgs() {
+	opts.redactArgs = true
+}
+
+// slowQueryLogger 慢查询日志钩子
+type slowQueryLogger struct {
+	threshold  time.Duration
+	logger     *log.Logger
+	redactArgs bool
+}
+
+// NewSlowQueryLogger 创建慢查询日志钩子，耗时超过thresholdMS毫秒的语句会输出到logger，logger为nil时使用标准日志
+func NewSlowQueryLogger(thresholdMS int64, logger *log.Logger, opts ...SlowLogOpts) Hook {
+	if logger == nil {
+		logger = log.New(log.Writer(), log.Prefix(), log.Flags())
+	}
+
+	l := &slowQueryLogger{
+		threshold: time.Duration(thresholdMS) * time.Millisecond,
+		logger:    logger,
+	}
+
+	if len(opts) > 0 {
+		l.redactArgs = opts[0].redactArgs
+	}
+
+	return l
+}
+
+func (l *slowQueryLogger) Before(ctx context.Context, e *QueryEvent) context.Context {
+	return ctx
+}
+
+func (l *slowQueryLogger) After(ctx context.Context, e *QueryEvent) {
+	if e.Duration < l.threshold {
+		return
+	}
+
+	var args interface{} = e.Args
+	if l.redactArgs {
+		args = fmt.Sprintf("<%d redacted>", len(e.Args))
+	}
+
+	l.logger.Printf("[slow %s] %.3fms rows:%d err:%v sql:%s args:%v",
+		e.Op, float64(e.Duration)/float64(time.Millisecond), e.RowsAffected, e.Err, e.SQL, args)
+}
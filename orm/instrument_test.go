@@ -0,0 +1,134 @@
+package orm_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"util/orm"
+	"util/orm/ormtest"
+)
+
+type countHook struct {
+	before int
+	after  []*orm.QueryEvent
+}
+
+func (h *countHook) Before(ctx context.Context, e *orm.QueryEvent) context.Context {
+	h.before++
+	return ctx
+}
+
+func (h *countHook) After(ctx context.Context, e *orm.QueryEvent) {
+	h.after = append(h.after, e)
+}
+
+var _ = Describe("Instrument", func() {
+
+	BeforeEach(requireMySQL)
+
+	Context("instrumented db", func() {
+		It("should be succeed", func() {
+			var buff bytes.Buffer
+
+			opts := orm.InstrumentOpts{}
+			opts.SetSlowThreshold(0)
+
+			idb := orm.NewInstrumentedDB(db, opts)
+			hook := &countHook{}
+			idb.AddHook(hook)
+			idb.AddHook(orm.NewSlowQueryLogger(0, log.New(&buff, "", 0)))
+
+			records, err := orm.Query(context.TODO(), idb, "SELECT user_id, user_name, city FROM sample WHERE user_id = ?", &UserInfo{}, 1001)
+			Expect(err).Should(Succeed())
+			Expect(len(records) == 1).Should(BeTrue())
+
+			_, err = orm.Exec(context.TODO(), idb, "UPDATE sample SET city = city WHERE user_id = ?", 1001)
+			Expect(err).Should(Succeed())
+
+			_, err = orm.Query(context.TODO(), idb, "SELECT * FROM not_exist_table", &UserInfo{})
+			Expect(err).ShouldNot(Succeed())
+
+			Expect(hook.before == 3 && len(hook.after) == 3).Should(BeTrue())
+			Expect(hook.after[0].Op == orm.OpQuery && hook.after[0].RowsAffected == -1).Should(BeTrue())
+			Expect(hook.after[1].Op == orm.OpExec && hook.after[1].Err == nil).Should(BeTrue())
+			Expect(hook.after[2].Err).ShouldNot(Succeed())
+			Expect(buff.String()).Should(ContainSubstring("[slow exec]"))
+
+			stats := idb.Stats()
+			Expect(stats.Queries == 2 && stats.Execs == 1 && stats.Errors == 1).Should(BeTrue())
+			Expect(stats.SlowQueries == 3).Should(BeTrue())
+			Expect(stats.Statements["SELECT * FROM not_exist_table"].Errors == 1).Should(BeTrue())
+
+			_, err = json.Marshal(stats)
+			Expect(err).Should(Succeed())
+		})
+	})
+})
+
+var _ = Describe("Instrument without database", func() {
+	It("should redact args in slow log", func() {
+		mockDB, mock := ormtest.New()
+		defer mockDB.Close()
+
+		mock.ExpectExec("UPDATE sample").WillReturnResult(0, 1)
+
+		var buff bytes.Buffer
+		opts := orm.SlowLogOpts{}
+		opts.SetRedactArgs()
+
+		idb := orm.NewInstrumentedDB(mockDB)
+		idb.AddHook(orm.NewSlowQueryLogger(0, log.New(&buff, "", 0), opts))
+
+		_, err := orm.Exec(context.TODO(), idb, "UPDATE sample SET user_name = ? WHERE user_id = ?", "secret", 1001)
+		Expect(err).Should(Succeed())
+		Expect(buff.String()).Should(ContainSubstring("args:<2 redacted>"))
+		Expect(buff.String()).ShouldNot(ContainSubstring("secret"))
+		Expect(mock.ExpectationsWereMet()).Should(Succeed())
+	})
+})
+
+var _ = Describe("Instrument with SQLite", func() {
+	It("should record statements in transactions", func() {
+		idb, err := orm.OpenInstrumented("sqlite3", ":memory:")
+		Expect(err).Should(Succeed())
+		defer idb.Close()
+
+		// 内存数据库的每个连接相互独立
+		idb.DB().SetMaxOpenConns(1)
+
+		hook := &countHook{}
+		idb.AddHook(hook)
+
+		_, err = orm.Exec(context.TODO(), idb, "CREATE TABLE sample (id INTEGER PRIMARY KEY, user_id INT NOT NULL, user_name TEXT NOT NULL, city TEXT NOT NULL)")
+		Expect(err).Should(Succeed())
+
+		err = orm.WithTx(context.TODO(), idb, nil, func(tx *sql.Tx) error {
+			_, err := tx.ExecContext(context.TODO(), "INSERT INTO sample (user_id, user_name, city) VALUES (?, ?, ?)", 1, "alice", "beijing")
+			return err
+		})
+		Expect(err).Should(Succeed())
+
+		opts := orm.BatchOpts{}
+		opts.SetDialect(orm.SQLite)
+		_, err = orm.BatchInsert(context.TODO(), idb, "sample", []UserInfo{{UserID: 2, UserName: "bob", City: "chengdu"}}, 0, opts)
+		Expect(err).Should(Succeed())
+
+		records, err := orm.Query(context.TODO(), idb, "SELECT user_id, user_name, city FROM sample WHERE user_id > ?", &UserInfo{}, 0)
+		Expect(err).Should(Succeed())
+		Expect(len(records) == 2).Should(BeTrue())
+
+		Expect(hook.before == 4 && len(hook.after) == 4).Should(BeTrue())
+		Expect(hook.after[1].RowsAffected == 1 && len(hook.after[1].Args) == 3).Should(BeTrue())
+		Expect(strings.HasPrefix(hook.after[2].SQL, "INSERT INTO sample")).Should(BeTrue())
+		Expect(hook.after[3].Op == orm.OpQuery).Should(BeTrue())
+
+		stats := idb.Stats()
+		Expect(stats.Queries == 1 && stats.Execs == 3 && stats.Errors == 0).Should(BeTrue())
+	})
+})
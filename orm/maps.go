@@ -0,0 +1,191 @@
+package orm
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+ * QueryMaps 将查询结果映射为 map，适用于没有对应数据对象的报表、管理工具等场景；
+ * 驱动返回的值按列类型转换：文本类型的 []byte 转为 string，整型转为 int64，浮点型转为 float64，
+ * 日期时间类型解析为 time.Time，DECIMAL 保留为 string 以免丢失精度，二进制类型保留为 []byte
+ */
+
+var timeLayouts = []string{
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02",
+}
+
+// ColumnMeta 列元数据，驱动不支持的属性为零值
+type ColumnMeta struct {
+	Name         string `json:"name"`
+	DatabaseType string `json:"database_type"` // 数据库类型名，如 VARCHAR、BIGINT
+	ScanType     string `json:"scan_type"`     // 驱动建议的Go类型
+	Nullable     bool   `json:"nullable"`
+	Length       int64  `json:"length"`    // 变长类型的长度
+	Precision    int64  `json:"precision"` // DECIMAL 精度
+	Scale        int64  `json:"scale"`     // DECIMAL 小数位数
+}
+
+// QueryMaps 执行sql语句，每行数据映射为 列名 -> 值 的map；结果中有重名的列时返回错误（如 JOIN 的多个表都有 id 列），需要使用别名区分
+func QueryMaps(ctx context.Context, db Executor, sql string, args ...interface{}) ([]map[string]interface{}, error) {
+	records, _, err := QueryMapsMeta(ctx, db, sql, args...)
+	return records, err
+}
+
+// QueryMapsMeta 同 QueryMaps，同时返回列元数据
+func QueryMapsMeta(ctx context.Context, db Executor, sql string, args ...interface{}) (records []map[string]interface{}, metas []*ColumnMeta, err error) {
+	rows, err := db.QueryContext(ctx, sql, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	defer func() {
+		if closeErr := rows.Close(); closeErr != nil && err == nil {
+			records, metas, err = nil, nil, closeErr
+		}
+	}()
+
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	metas = make([]*ColumnMeta, 0, len(types))
+	seen := make(map[string]bool, len(types))
+	for _, t := range types {
+		meta := columnMeta(t)
+		if seen[meta.Name] {
+			return nil, nil, fmt.Errorf("duplicate column %s in result, use an alias", meta.Name)
+		}
+
+		seen[meta.Name] = true
+		metas = append(metas, meta)
+	}
+
+	values := make([]interface{}, len(metas))
+	dest := make([]interface{}, len(metas))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+
+	for i := 0; rows.Next(); i++ {
+		if err := rows.Scan(dest...); err != nil {
+			return nil, nil, &ScanError{Row: i, Column: scanErrColumn(err, columnNames(metas)), Err: err}
+		}
+
+		record := make(map[string]interface{}, len(metas))
+		for j, meta := range metas {
+			record[meta.Name] = convertValue(values[j], meta.DatabaseType)
+		}
+
+		records = append(records, record)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	return records, metas, nil
+}
+
+func columnMeta(t *sql.ColumnType) *ColumnMeta {
+	meta := &ColumnMeta{Name: t.Name(), DatabaseType: strings.ToUpper(t.DatabaseTypeName())}
+
+	if st := t.ScanType(); st != nil {
+		meta.ScanType = st.String()
+	}
+
+	meta.Nullable, _ = t.Nullable()
+	meta.Length, _ = t.Length()
+	meta.Precision, meta.Scale, _ = t.DecimalSize()
+
+	return meta
+}
+
+func columnNames(metas []*ColumnMeta) []string {
+	names := make([]string, 0, len(metas))
+	for _, meta := range metas {
+		names = append(names, meta.Name)
+	}
+
+	return names
+}
+
+// convertValue 按列类型转换驱动返回的值，转换失败时保留原值（[]byte 转为 string）
+func convertValue(v interface{}, dbType string) interface{} {
+	var s string
+	switch value := v.(type) {
+	case []byte:
+		if isBinaryType(dbType) {
+			cp := make([]byte, len(value))
+			copy(cp, value)
+			return cp
+		}
+		s = string(value)
+	case string:
+		s = value
+	default:
+		return v
+	}
+
+	switch {
+	case isIntType(dbType):
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return n
+		}
+		if n, err := strconv.ParseUint(s, 10, 64); err == nil {
+			return n
+		}
+	case isFloatType(dbType):
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f
+		}
+	case isTimeType(dbType):
+		for _, layout := range timeLayouts {
+			if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+				return t
+			}
+		}
+	}
+
+	return s
+}
+
+func isBinaryType(t string) bool {
+	return strings.Contains(t, "BLOB") || strings.Contains(t, "BINARY") || t == "BYTEA" || t == "BIT"
+}
+
+func isIntType(t string) bool {
+	t = strings.TrimPrefix(t, "UNSIGNED ")
+	switch t {
+	case "TINYINT", "SMALLINT", "MEDIUMINT", "INT", "INTEGER", "BIGINT", "YEAR", "INT2", "INT4", "INT8", "SERIAL", "BIGSERIAL":
+		return true
+	}
+
+	return false
+}
+
+func isFloatType(t string) bool {
+	switch t {
+	case "FLOAT", "DOUBLE", "REAL", "FLOAT4", "FLOAT8", "DOUBLE PRECISION":
+		return true
+	}
+
+	return false
+}
+
+func isTimeType(t string) bool {
+	switch t {
+	case "DATE", "DATETIME", "TIMESTAMP", "TIMESTAMPTZ":
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,57 @@
+package orm_test
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"util/orm"
+	"util/orm/ormtest"
+)
+
+var _ = Describe("Maps", func() {
+
+	BeforeEach(requireMySQL)
+
+	Context("query maps", func() {
+		It("should convert driver values", func() {
+			records, metas, err := orm.QueryMapsMeta(context.TODO(), db,
+				"SELECT user_id, user_name, NOW() AS now, CAST(1.5 AS DECIMAL(5,2)) AS price, NULL AS nothing FROM sample WHERE user_id = ?", 1003)
+			Expect(err).Should(Succeed())
+			Expect(len(records) == 1).Should(BeTrue())
+			Expect(len(metas) == 5).Should(BeTrue())
+			Expect(metas[0].Name).Should(Equal("user_id"))
+			Expect(metas[1].DatabaseType).Should(Equal("VARCHAR"))
+
+			r := records[0]
+			Expect(r["user_id"]).Should(Equal(int64(1003)))
+			Expect(r["user_name"]).Should(Equal("user_3"))
+			Expect(r["price"]).Should(Equal("1.50"))
+			Expect(r["nothing"]).Should(BeNil())
+
+			_, ok := r["now"].(time.Time)
+			Expect(ok).Should(BeTrue())
+		})
+
+		It("should return empty result", func() {
+			records, err := orm.QueryMaps(context.TODO(), db, "SELECT * FROM sample WHERE user_id < 0")
+			Expect(err).Should(Succeed())
+			Expect(len(records) == 0).Should(BeTrue())
+		})
+	})
+})
+
+var _ = Describe("Maps without database", func() {
+	It("should reject duplicate columns", func() {
+		mockDB, mock := ormtest.New()
+		defer mockDB.Close()
+
+		mock.ExpectQuery("FROM sample JOIN bill").WillReturnRows(ormtest.NewRows("id", "user_id", "id").AddRow(1, 1000, 2))
+
+		_, err := orm.QueryMaps(context.TODO(), mockDB, "SELECT sample.id, sample.user_id, bill.id FROM sample JOIN bill ON bill.user_id = sample.user_id")
+		Expect(err != nil && strings.Contains(err.Error(), "duplicate column id")).Should(BeTrue())
+		Expect(mock.ExpectationsWereMet()).Should(Succeed())
+	})
+})
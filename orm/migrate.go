@@ -0,0 +1,541 @@
+package orm
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+ * Migrator 数据库版本迁移，已执行的版本记录在 schema_migrations 表中，执行期间通过 schema_migrations_lock 表加锁，
+ * 防止多个实例同时迁移；持有锁期间定期刷新加锁时间，超过有效期未刷新的锁视为持有者已退出，可以被其他实例抢占；
+ * 每个版本在独立的事务中执行（注意 MySQL 的 DDL 会隐式提交事务）
+ *  e.g
+ *  migrations, err := orm.LoadMigrations(os.DirFS("."), "migrations")
+ *  m, err := orm.NewMigrator(db, orm.MySQL, migrations)
+ *  applied, err := m.Migrate(ctx)
+ */
+
+const (
+	defaultMigrationTable = "schema_migrations"
+	defaultLockTimeoutMS  = 30 * 1e3      // 默认等待锁超时时间30s
+	defaultLockTTLMS      = 10 * 60 * 1e3 // 超过10分钟未刷新的锁视为失效
+	lockRetryIntervalMS   = 200
+)
+
+// ErrLockTimeout 等待迁移锁超时
+var ErrLockTimeout = errors.New("migration lock timeout")
+
+// Migration 一个版本的迁移，Up/Down 为sql语句，多条语句以 ; 分隔；也可以使用 UpFunc/DownFunc，二者同时设置时优先使用函数
+type Migration struct {
+	Version  int64
+	Name     string
+	Up       string
+	Down     string
+	UpFunc   func(ctx context.Context, tx *sql.Tx) error
+	DownFunc func(ctx context.Context, tx *sql.Tx) error
+}
+
+// MigrationStatus 迁移版本状态
+type MigrationStatus struct {
+	Version   int64  `json:"version"`
+	Name      string `json:"name"`
+	Applied   bool   `json:"applied"`
+	AppliedAt int64  `json:"applied_at"` // 执行时间，unix秒
+}
+
+// MigratorOpts 迁移选项
+type MigratorOpts struct {
+	table         *string // 版本记录表名，锁表名为 <table>_lock
+	dryRun        bool    // 只计算需要执行的版本，不执行
+	lockTimeoutMS *int    // 等待锁超时时间(毫秒)
+	lockTTLMS     *int    // 锁的有效期(毫秒)
+}
+
+// SetTable 设置版本记录表名
+func (opts *MigratorOpts) SetTable(table string) {
+	opts.table = &table
+}
+
+// SetDryRun 开启演练模式，Migrate/Rollback 只返回将要执行的版本，不修改数据库
+func (opts *MigratorOpts) SetDryRun() {
+	opts.dryRun = true
+}
+
+// SetLockTimeout 设置等待迁移锁的超时时间(毫秒)
+func (opts *MigratorOpts) SetLockTimeout(ms int) {
+	opts.lockTimeoutMS = &ms
+}
+
+// SetLockTTL 设置锁的有效期(毫秒)，持有锁期间每隔1/3有效期刷新一次，不大于0时为10分钟
+func (opts *MigratorOpts) SetLockTTL(ms int) {
+	opts.lockTTLMS = &ms
+}
+
+// Migrator 迁移执行器
+type Migrator struct {
+	db            TxBeginner
+	dialect       Dialect
+	table         string
+	dryRun        bool
+	lockTimeoutMS int
+	lockTTL       time.Duration
+	owner         string
+	migrations    []*Migration
+}
+
+// NewMigrator 创建迁移执行器，migrations无需有序，但版本号不能重复
+func NewMigrator(db TxBeginner, dialect Dialect, migrations []*Migration, opts ...MigratorOpts) (*Migrator, error) {
+	m := &Migrator{
+		db:            db,
+		dialect:       dialect,
+		table:         defaultMigrationTable,
+		lockTimeoutMS: defaultLockTimeoutMS,
+		lockTTL:       defaultLockTTLMS * time.Millisecond,
+		owner:         fmt.Sprintf("%d-%d", os.Getpid(), time.Now().UnixNano()),
+		migrations:    make([]*Migration, len(migrations)),
+	}
+
+	if len(opts) > 0 {
+		opt := opts[0]
+
+		if opt.table != nil {
+			m.table = *opt.table
+		}
+
+		if opt.lockTimeoutMS != nil {
+			m.lockTimeoutMS = *opt.lockTimeoutMS
+		}
+
+		if opt.lockTTLMS != nil && *opt.lockTTLMS > 0 {
+			m.lockTTL = time.Duration(*opt.lockTTLMS) * time.Millisecond
+		}
+
+		m.dryRun = opt.dryRun
+	}
+
+	copy(m.migrations, migrations)
+	sort.Slice(m.migrations, func(i, j int) bool {
+		return m.migrations[i].Version < m.migrations[j].Version
+	})
+
+	for i := 1; i < len(m.migrations); i++ {
+		if m.migrations[i].Version == m.migrations[i-1].Version {
+			return nil, fmt.Errorf("duplicate migration version %d", m.migrations[i].Version)
+		}
+	}
+
+	return m, nil
+}
+
+// Migrate 按版本号顺序执行所有未执行的迁移，返回本次执行（演练模式下为将要执行）的版本
+func (m *Migrator) Migrate(ctx context.Context) ([]*Migration, error) {
+	var done []*Migration
+	err := m.run(ctx, func(ctx context.Context, applied map[int64]int64) error {
+		for _, mig := range m.migrations {
+			if _, ok := applied[mig.Version]; ok {
+				continue
+			}
+
+			if !m.dryRun {
+				if err := m.apply(ctx, mig, true); err != nil {
+					return fmt.Errorf("migrate %d_%s: %w", mig.Version, mig.Name, err)
+				}
+			}
+
+			done = append(done, mig)
+		}
+
+		return nil
+	})
+
+	return done, err
+}
+
+// Rollback 按版本号倒序回滚最近执行的steps个版本，返回本次回滚（演练模式下为将要回滚）的版本；
+// 遇到没有 Down 及 DownFunc 的版本时停止并返回错误
+func (m *Migrator) Rollback(ctx context.Context, steps int) ([]*Migration, error) {
+	var done []*Migration
+	err := m.run(ctx, func(ctx context.Context, applied map[int64]int64) error {
+		for i := len(m.migrations) - 1; i >= 0 && len(done) < steps; i-- {
+			mig := m.migrations[i]
+			if _, ok := applied[mig.Version]; !ok {
+				continue
+			}
+
+			// 没有回滚步骤时不删除版本记录，避免版本记录与表结构不一致
+			if strings.TrimSpace(mig.Down) == "" && mig.DownFunc == nil {
+				return fmt.Errorf("rollback %d_%s: migration %d has no down step", mig.Version, mig.Name, mig.Version)
+			}
+
+			if !m.dryRun {
+				if err := m.apply(ctx, mig, false); err != nil {
+					return fmt.Errorf("rollback %d_%s: %w", mig.Version, mig.Name, err)
+				}
+			}
+
+			done = append(done, mig)
+		}
+
+		return nil
+	})
+
+	return done, err
+}
+
+// Status 查看所有版本的执行状态
+func (m *Migrator) Status(ctx context.Context) ([]*MigrationStatus, error) {
+	if err := m.ensureTables(ctx); err != nil {
+		return nil, err
+	}
+
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	status := make([]*MigrationStatus, 0, len(m.migrations))
+	for _, mig := range m.migrations {
+		at, ok := applied[mig.Version]
+		status = append(status, &MigrationStatus{Version: mig.Version, Name: mig.Name, Applied: ok, AppliedAt: at})
+	}
+
+	return status, nil
+}
+
+// run 加锁后读取已执行的版本并回调fn；演练模式下不加锁，版本记录表不存在时视为没有执行过任何版本；
+// 刷新锁失败时取消传给fn的ctx，并返回刷新失败的原因
+func (m *Migrator) run(ctx context.Context, fn func(ctx context.Context, applied map[int64]int64) error) (err error) {
+	if m.dryRun {
+		applied, err := m.applied(ctx)
+		if err != nil {
+			applied = map[int64]int64{}
+		}
+
+		return fn(ctx, applied)
+	}
+
+	if err := m.ensureTables(ctx); err != nil {
+		return err
+	}
+
+	if err := m.lock(ctx); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	stop := m.heartbeat(ctx, cancel)
+	defer func() {
+		if lost := stop(); lost != nil {
+			err = lost
+		}
+
+		if unlockErr := m.unlock(); unlockErr != nil && err == nil {
+			err = fmt.Errorf("unlock migration: %w", unlockErr)
+		}
+	}()
+
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return err
+	}
+
+	return fn(ctx, applied)
+}
+
+func (m *Migrator) ensureTables(ctx context.Context) error {
+	scripts := []string{
+		fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (version BIGINT NOT NULL PRIMARY KEY, name VARCHAR(255) NOT NULL, applied_at BIGINT NOT NULL)", m.table),
+		fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s_lock (id INT NOT NULL PRIMARY KEY, owner VARCHAR(64) NOT NULL, locked_at BIGINT NOT NULL)", m.table),
+	}
+
+	for _, script := range scripts {
+		if _, err := m.db.ExecContext(ctx, script); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m *Migrator) applied(ctx context.Context) (map[int64]int64, error) {
+	rows, err := m.db.QueryContext(ctx, fmt.Sprintf("SELECT version, applied_at FROM %s", m.table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int64]int64)
+	for rows.Next() {
+		var version, at int64
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		applied[version] = at
+	}
+
+	return applied, rows.Err()
+}
+
+// lock 通过向锁表写入主键固定的一行实现互斥，写入失败说明其他实例持有锁，等待后重试
+func (m *Migrator) lock(ctx context.Context) error {
+	insert := m.dialect.Rebind(fmt.Sprintf("INSERT INTO %s_lock (id, owner, locked_at) VALUES (1, ?, ?)", m.table))
+	expire := m.dialect.Rebind(fmt.Sprintf("DELETE FROM %s_lock WHERE id = 1 AND locked_at < ?", m.table))
+
+	deadline := time.Now().Add(time.Duration(m.lockTimeoutMS) * time.Millisecond)
+	for {
+		now := time.Now()
+		if _, err := m.db.ExecContext(ctx, insert, m.owner, now.Unix()); err == nil {
+			return nil
+		}
+
+		// 清理失效的锁，防止持有锁的实例异常退出后无法再次迁移
+		if _, err := m.db.ExecContext(ctx, expire, now.Add(-m.lockTTL).Unix()); err != nil {
+			return fmt.Errorf("expire migration lock: %w", err)
+		}
+
+		if now.After(deadline) {
+			return ErrLockTimeout
+		}
+
+		timer := time.NewTimer(lockRetryIntervalMS * time.Millisecond)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// heartbeat 持有锁期间定期刷新加锁时间，刷新失败或锁已被其他实例抢占时调用cancel；
+// 返回的函数停止刷新，并返回刷新失败的原因
+func (m *Migrator) heartbeat(ctx context.Context, cancel context.CancelFunc) func() error {
+	refresh := m.dialect.Rebind(fmt.Sprintf("UPDATE %s_lock SET locked_at = ? WHERE id = 1 AND owner = ?", m.table))
+
+	var lost error
+	done, stopped := make(chan struct{}), make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+
+		ticker := time.NewTicker(m.lockTTL / 3)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			if err := m.refresh(ctx, refresh); err != nil {
+				// 调用方取消时不视为丢失锁
+				if ctx.Err() != nil {
+					return
+				}
+
+				lost = fmt.Errorf("refresh migration lock: %w", err)
+				cancel()
+				return
+			}
+		}
+	}()
+
+	return func() error {
+		close(done)
+		<-stopped
+		cancel()
+		return lost
+	}
+}
+
+// refresh 刷新加锁时间；加锁时间未变化时部分数据库返回的影响行数为0，此时通过查询确认锁仍被当前实例持有
+func (m *Migrator) refresh(ctx context.Context, script string) error {
+	result, err := m.db.ExecContext(ctx, script, time.Now().Unix(), m.owner)
+	if err != nil {
+		return err
+	}
+
+	if n, err := result.RowsAffected(); err == nil && n > 0 {
+		return nil
+	}
+
+	var owner string
+	query := fmt.Sprintf("SELECT owner FROM %s_lock WHERE id = 1", m.table)
+	rows, err := m.db.QueryContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	if rows.Next() {
+		if err := rows.Scan(&owner); err != nil {
+			return err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if owner != m.owner {
+		return errors.New("lock taken by another runner")
+	}
+
+	return nil
+}
+
+func (m *Migrator) unlock() error {
+	script := m.dialect.Rebind(fmt.Sprintf("DELETE FROM %s_lock WHERE id = 1 AND owner = ?", m.table))
+	_, err := m.db.ExecContext(context.Background(), script, m.owner)
+	return err
+}
+
+func (m *Migrator) apply(ctx context.Context, mig *Migration, up bool) error {
+	opts := &TxOpts{}
+	opts.SetRetry(0, 0)
+
+	return WithTx(ctx, m.db, opts, func(tx *sql.Tx) error {
+		script, fn := mig.Up, mig.UpFunc
+		if !up {
+			script, fn = mig.Down, mig.DownFunc
+		}
+
+		if fn != nil {
+			if err := fn(ctx, tx); err != nil {
+				return err
+			}
+		} else {
+			for _, stmt := range SplitStatements(script) {
+				if _, err := tx.ExecContext(ctx, stmt); err != nil {
+					return err
+				}
+			}
+		}
+
+		if up {
+			script := m.dialect.Rebind(fmt.Sprintf("INSERT INTO %s (version, name, applied_at) VALUES (?, ?, ?)", m.table))
+			_, err := tx.ExecContext(ctx, script, mig.Version, mig.Name, time.Now().Unix())
+			return err
+		}
+
+		script = m.dialect.Rebind(fmt.Sprintf("DELETE FROM %s WHERE version = ?", m.table))
+		_, err := tx.ExecContext(ctx, script, mig.Version)
+		return err
+	})
+}
+
+// LoadMigrations 读取fsys中dir目录下的迁移文件，文件名格式为 "<version>_<name>.up.sql" 及 "<version>_<name>.down.sql"
+//  e.g
+//  0001_create_user.up.sql
+//  0001_create_user.down.sql
+func LoadMigrations(fsys fs.FS, dir string) ([]*Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	m := make(map[int64]*Migration)
+	for _, entry := range entries {
+		fileName := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(fileName, ".sql") {
+			continue
+		}
+
+		base := strings.TrimSuffix(fileName, ".sql")
+		up := strings.HasSuffix(base, ".up")
+		if !up && !strings.HasSuffix(base, ".down") {
+			return nil, fmt.Errorf("migration file %s: need .up.sql or .down.sql suffix", fileName)
+		}
+
+		base = strings.TrimSuffix(strings.TrimSuffix(base, ".up"), ".down")
+		parts := strings.SplitN(base, "_", 2)
+
+		version, err := strconv.ParseInt(parts[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migration file %s: invalid version", fileName)
+		}
+
+		data, err := fs.ReadFile(fsys, path.Join(dir, fileName))
+		if err != nil {
+			return nil, err
+		}
+
+		mig, ok := m[version]
+		if !ok {
+			mig = &Migration{Version: version}
+			if len(parts) == 2 {
+				mig.Name = parts[1]
+			}
+			m[version] = mig
+		}
+
+		if up {
+			mig.Up = string(data)
+		} else {
+			mig.Down = string(data)
+		}
+	}
+
+	migrations := make([]*Migration, 0, len(m))
+	for _, mig := range m {
+		migrations = append(migrations, mig)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// SplitStatements 将以 ; 分隔的多条sql语句拆分，忽略引号及 -- 注释中的 ;
+func SplitStatements(script string) []string {
+	var stmts []string
+	var b strings.Builder
+
+	var quote byte
+	for i := 0; i < len(script); i++ {
+		c := script[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '-' && i+1 < len(script) && script[i+1] == '-':
+			// 跳过注释直到行尾
+			for i < len(script) && script[i] != '\n' {
+				i++
+			}
+			b.WriteByte('\n')
+			continue
+		case c == ';':
+			if stmt := strings.TrimSpace(b.String()); stmt != "" {
+				stmts = append(stmts, stmt)
+			}
+			b.Reset()
+			continue
+		}
+
+		b.WriteByte(c)
+	}
+
+	if stmt := strings.TrimSpace(b.String()); stmt != "" {
+		stmts = append(stmts, stmt)
+	}
+
+	return stmts
+}
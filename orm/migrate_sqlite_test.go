@@ -0,0 +1,109 @@
+package orm_test
+
+import (
+	"context"
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"util/orm"
+)
+
+var _ = Describe("Migrate with SQLite", func() {
+	var (
+		dir  string
+		lite *sql.DB
+	)
+
+	migrations := []*orm.Migration{
+		{Version: 1, Name: "create_notes", Up: "CREATE TABLE notes (id INTEGER PRIMARY KEY, body TEXT NOT NULL); CREATE INDEX idx_body ON notes (body)", Down: "DROP TABLE notes"},
+		{Version: 2, Name: "seed_notes", Up: "INSERT INTO notes (body) VALUES ('hello')"},
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "migrate")
+		Expect(err).Should(Succeed())
+
+		lite, err = sql.Open("sqlite3", "file:"+filepath.Join(dir, "test.db")+"?_busy_timeout=5000")
+		Expect(err).Should(Succeed())
+	})
+
+	AfterEach(func() {
+		lite.Close()
+		os.RemoveAll(dir)
+	})
+
+	tableExists := func(name string) bool {
+		var n int
+		err := lite.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?", name).Scan(&n)
+		Expect(err).Should(Succeed())
+		return n == 1
+	}
+
+	It("should migrate up and down", func() {
+		m, err := orm.NewMigrator(lite, orm.SQLite, migrations)
+		Expect(err).Should(Succeed())
+
+		applied, err := m.Migrate(context.TODO())
+		Expect(err).Should(Succeed())
+		Expect(len(applied) == 2 && tableExists("notes")).Should(BeTrue())
+
+		var body string
+		Expect(lite.QueryRow("SELECT body FROM notes").Scan(&body)).Should(Succeed())
+		Expect(body == "hello").Should(BeTrue())
+
+		// 版本2没有回滚步骤，版本记录保持不变
+		_, err = m.Rollback(context.TODO(), 1)
+		Expect(err != nil && strings.Contains(err.Error(), "migration 2 has no down step")).Should(BeTrue())
+
+		status, err := m.Status(context.TODO())
+		Expect(err).Should(Succeed())
+		Expect(status[0].Applied && status[1].Applied).Should(BeTrue())
+
+		migrations[1].DownFunc = func(ctx context.Context, tx *sql.Tx) error {
+			_, err := tx.ExecContext(ctx, "DELETE FROM notes")
+			return err
+		}
+		defer func() { migrations[1].DownFunc = nil }()
+
+		rolled, err := m.Rollback(context.TODO(), 2)
+		Expect(err).Should(Succeed())
+		Expect(len(rolled) == 2 && rolled[0].Version == 2 && !tableExists("notes")).Should(BeTrue())
+
+		status, err = m.Status(context.TODO())
+		Expect(err).Should(Succeed())
+		Expect(!status[0].Applied && !status[1].Applied).Should(BeTrue())
+	})
+
+	It("should wait for lock held by another runner", func() {
+		opts := orm.MigratorOpts{}
+		opts.SetLockTimeout(300)
+
+		m, err := orm.NewMigrator(lite, orm.SQLite, migrations, opts)
+		Expect(err).Should(Succeed())
+
+		_, err = m.Status(context.TODO())
+		Expect(err).Should(Succeed())
+
+		_, err = lite.Exec("INSERT INTO schema_migrations_lock (id, owner, locked_at) VALUES (1, 'other', ?)", time.Now().Unix())
+		Expect(err).Should(Succeed())
+
+		_, err = m.Migrate(context.TODO())
+		Expect(err == orm.ErrLockTimeout && !tableExists("notes")).Should(BeTrue())
+
+		// 持有锁的实例退出后可以继续迁移
+		_, err = lite.Exec("DELETE FROM schema_migrations_lock")
+		Expect(err).Should(Succeed())
+
+		applied, err := m.Migrate(context.TODO())
+		Expect(err).Should(Succeed())
+		Expect(len(applied) == 2 && tableExists("notes")).Should(BeTrue())
+	})
+})
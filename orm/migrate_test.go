@@ -0,0 +1,204 @@
+package orm_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"testing/fstest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"util/orm"
+	"util/orm/ormtest"
+)
+
+var _ = Describe("Migrate", func() {
+
+	fsys := fstest.MapFS{
+		"migrations/0001_create_orders.up.sql":   {Data: []byte("CREATE TABLE mig_orders (id BIGINT NOT NULL PRIMARY KEY, note VARCHAR(32) NOT NULL DEFAULT 'a;b')")},
+		"migrations/0001_create_orders.down.sql": {Data: []byte("DROP TABLE mig_orders")},
+		"migrations/0002_seed_orders.up.sql":     {Data: []byte("INSERT INTO mig_orders (id) VALUES (1); -- first;\nINSERT INTO mig_orders (id) VALUES (2);")},
+		"migrations/0002_seed_orders.down.sql":   {Data: []byte("DELETE FROM mig_orders")},
+	}
+
+	Context("split statements", func() {
+		It("should ignore ; in quotes and comments", func() {
+			stmts := orm.SplitStatements("INSERT INTO t VALUES ('a;b'); -- c;d\nDELETE FROM t;;")
+			Expect(stmts).Should(Equal([]string{"INSERT INTO t VALUES ('a;b')", "DELETE FROM t"}))
+		})
+	})
+
+	Context("migrate and rollback", func() {
+		It("should be succeed", func() {
+			requireMySQL()
+
+			migrations, err := orm.LoadMigrations(fsys, "migrations")
+			Expect(err).Should(Succeed())
+			Expect(len(migrations) == 2).Should(BeTrue())
+			Expect(migrations[0].Name).Should(Equal("create_orders"))
+
+			counted := 0
+			migrations = append(migrations, &orm.Migration{
+				Version: 3,
+				Name:    "count_orders",
+				UpFunc: func(ctx context.Context, tx *sql.Tx) error {
+					return tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM mig_orders").Scan(&counted)
+				},
+				DownFunc: func(ctx context.Context, tx *sql.Tx) error {
+					return nil
+				},
+			})
+
+			opts := orm.MigratorOpts{}
+			opts.SetTable("mig_versions")
+			opts.SetDryRun()
+
+			dry, err := orm.NewMigrator(db, orm.MySQL, migrations, opts)
+			Expect(err).Should(Succeed())
+
+			planned, err := dry.Migrate(context.TODO())
+			Expect(err).Should(Succeed())
+			Expect(len(planned) == 3).Should(BeTrue())
+
+			opts = orm.MigratorOpts{}
+			opts.SetTable("mig_versions")
+
+			m, err := orm.NewMigrator(db, orm.MySQL, migrations, opts)
+			Expect(err).Should(Succeed())
+
+			applied, err := m.Migrate(context.TODO())
+			Expect(err).Should(Succeed())
+			Expect(len(applied) == 3).Should(BeTrue())
+			Expect(counted == 2).Should(BeTrue())
+
+			applied, err = m.Migrate(context.TODO())
+			Expect(err).Should(Succeed())
+			Expect(len(applied) == 0).Should(BeTrue())
+
+			rolled, err := m.Rollback(context.TODO(), 2)
+			Expect(err).Should(Succeed())
+			Expect(len(rolled) == 2 && rolled[0].Version == 3 && rolled[1].Version == 2).Should(BeTrue())
+
+			status, err := m.Status(context.TODO())
+			Expect(err).Should(Succeed())
+			Expect(status[0].Applied && !status[1].Applied && !status[2].Applied).Should(BeTrue())
+
+			_, err = m.Rollback(context.TODO(), 10)
+			Expect(err).Should(Succeed())
+		})
+
+		It("should reject duplicate versions", func() {
+			_, err := orm.NewMigrator(db, orm.MySQL, []*orm.Migration{{Version: 1}, {Version: 1}})
+			Expect(err).ShouldNot(Succeed())
+		})
+	})
+})
+
+var _ = Describe("Migrate without database", func() {
+	var (
+		mockDB *sql.DB
+		mock   *ormtest.Mock
+		opts   orm.MigratorOpts
+	)
+
+	BeforeEach(func() {
+		mockDB, mock = ormtest.New()
+
+		opts = orm.MigratorOpts{}
+		opts.SetTable("mig_versions")
+
+		mock.ExpectExec("CREATE TABLE IF NOT EXISTS mig_versions (").WillReturnResult(0, 0)
+		mock.ExpectExec("CREATE TABLE IF NOT EXISTS mig_versions_lock").WillReturnResult(0, 0)
+	})
+
+	AfterEach(func() {
+		mockDB.Close()
+	})
+
+	// waitTriggered 等待mock执行到包含query的预期
+	waitTriggered := func(query string) {
+		Eventually(func() bool {
+			err := mock.ExpectationsWereMet()
+			return err == nil || !strings.Contains(err.Error(), query)
+		}, "2s", "10ms").Should(BeTrue())
+	}
+
+	It("should refresh lock while migrating", func() {
+		mock.ExpectExec("INSERT INTO mig_versions_lock").WillReturnResult(0, 1)
+		mock.ExpectQuery("SELECT version, applied_at FROM mig_versions").WillReturnRows(ormtest.NewRows("version", "applied_at"))
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE mig_versions_lock SET locked_at").WillReturnResult(0, 1)
+		mock.ExpectExec("INSERT INTO mig_versions (version").WithArgs(int64(1), "slow", ormtest.AnyArg()).WillReturnResult(0, 1)
+		mock.ExpectCommit()
+		mock.ExpectExec("DELETE FROM mig_versions_lock WHERE id = 1 AND owner").WillReturnResult(0, 1)
+
+		opts.SetLockTTL(300)
+		m, err := orm.NewMigrator(mockDB, orm.SQLite, []*orm.Migration{{
+			Version: 1,
+			Name:    "slow",
+			UpFunc: func(ctx context.Context, tx *sql.Tx) error {
+				waitTriggered("locked_at")
+				return nil
+			},
+		}}, opts)
+		Expect(err).Should(Succeed())
+
+		applied, err := m.Migrate(context.TODO())
+		Expect(err).Should(Succeed())
+		Expect(len(applied) == 1).Should(BeTrue())
+		Expect(mock.ExpectationsWereMet()).Should(Succeed())
+	})
+
+	It("should stop when lock taken by another runner", func() {
+		mock.ExpectExec("INSERT INTO mig_versions_lock").WillReturnResult(0, 1)
+		mock.ExpectQuery("SELECT version, applied_at FROM mig_versions").WillReturnRows(ormtest.NewRows("version", "applied_at"))
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE mig_versions_lock SET locked_at").WillReturnResult(0, 0)
+		mock.ExpectQuery("SELECT owner FROM mig_versions_lock").WillReturnRows(ormtest.NewRows("owner").AddRow("other"))
+		mock.ExpectRollback()
+		mock.ExpectExec("DELETE FROM mig_versions_lock WHERE id = 1 AND owner").WillReturnResult(0, 0)
+
+		opts.SetLockTTL(300)
+		m, err := orm.NewMigrator(mockDB, orm.SQLite, []*orm.Migration{{
+			Version: 1,
+			Name:    "slow",
+			UpFunc: func(ctx context.Context, tx *sql.Tx) error {
+				<-ctx.Done()
+				return ctx.Err()
+			},
+		}}, opts)
+		Expect(err).Should(Succeed())
+
+		_, err = m.Migrate(context.TODO())
+		Expect(err != nil && strings.Contains(err.Error(), "lock taken by another runner")).Should(BeTrue())
+		Expect(mock.ExpectationsWereMet()).Should(Succeed())
+	})
+
+	It("should wait for lock held by another runner", func() {
+		held := errors.New("UNIQUE constraint failed")
+		mock.ExpectExec("INSERT INTO mig_versions_lock").WillReturnError(held)
+		mock.ExpectExec("DELETE FROM mig_versions_lock WHERE id = 1 AND locked_at <").WillReturnResult(0, 0)
+
+		opts.SetLockTimeout(0)
+		m, err := orm.NewMigrator(mockDB, orm.SQLite, nil, opts)
+		Expect(err).Should(Succeed())
+
+		_, err = m.Migrate(context.TODO())
+		Expect(err == orm.ErrLockTimeout).Should(BeTrue())
+		Expect(mock.ExpectationsWereMet()).Should(Succeed())
+	})
+
+	It("should report expire error", func() {
+		broken := errors.New("disk I/O error")
+		mock.ExpectExec("INSERT INTO mig_versions_lock").WillReturnError(errors.New("UNIQUE constraint failed"))
+		mock.ExpectExec("DELETE FROM mig_versions_lock WHERE id = 1 AND locked_at <").WillReturnError(broken)
+
+		m, err := orm.NewMigrator(mockDB, orm.SQLite, nil, opts)
+		Expect(err).Should(Succeed())
+
+		_, err = m.Migrate(context.TODO())
+		Expect(errors.Is(err, broken)).Should(BeTrue())
+		Expect(mock.ExpectationsWereMet()).Should(Succeed())
+	})
+})
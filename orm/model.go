@@ -0,0 +1,214 @@
+package orm
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+/*
+ * 数据对象描述：列名取自"db"描述符，列属性取自"orm"描述符，多个属性以 ; 分隔
+ *  e.g
+ *  type User struct {
+ *      ID       int64     `db:"id" orm:"pk;auto_increment"`
+ *      Name     string    `db:"name" orm:"size:64;index"`
+ *      Email    *string   `db:"email" orm:"size:128;unique"`
+ *      Age      int       `db:"age" orm:"default:0;index:idx_age_name"`
+ *      Balance  float64   `db:"balance" orm:"type:DECIMAL(10,2)"`
+ *  }
+ *
+ *  pk               主键
+ *  auto_increment   自增
+ *  size:N           字符串长度
+ *  null / not null  是否可以为空，默认指针及 sql.NullXXX 类型可以为空，其他类型不能为空
+ *  default:V        默认值，原样写入建表语句
+ *  index[:name]     普通索引，同名索引组成联合索引
+ *  unique[:name]    唯一索引，同名索引组成联合索引
+ *  type:T           指定列类型，不再根据成员类型推导
+ *
+ * 关联成员（has_one、has_many、belongs_to）不对应列，详见 Preload
+ */
+
+const ormTagName = "orm"
+
+// Tabler 自定义表名，未实现时表名为结构体名的蛇形写法，如 UserInfo 对应 user_info
+type Tabler interface {
+	TableName() string
+}
+
+type fieldInfo struct {
+	index         int          // 成员下标
+	name          string       // 成员名
+	column        string       // 列名
+	typ           reflect.Type // 成员类型
+	pk            bool
+	autoIncrement bool
+	nullable      bool
+	size          int
+	def           *string
+	sqlType       string
+	indexName     string
+	uniqueName    string
+}
+
+type modelInfo struct {
+	typ    reflect.Type
+	table  string
+	fields []*fieldInfo
+	assocs []*assocInfo
+}
+
+var models sync.Map // reflect.Type -> *modelInfo
+
+// parseModel 解析数据对象，modelPtr为数据对象的指针；未设置"db"描述符或描述符为"-"的成员被忽略
+func parseModel(modelPtr interface{}) (*modelInfo, error) {
+	if reflect.ValueOf(modelPtr).Kind() != reflect.Ptr {
+		return nil, errors.New("need a pointer")
+	}
+
+	t := reflect.TypeOf(modelPtr).Elem()
+	if t.Kind() != reflect.Struct {
+		return nil, errors.New("need a pointer to struct")
+	}
+
+	if m, ok := models.Load(t); ok {
+		return m.(*modelInfo), nil
+	}
+
+	m := &modelInfo{typ: t, table: toSnake(t.Name())}
+	if tabler, ok := modelPtr.(Tabler); ok {
+		m.table = tabler.TableName()
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+
+		if isAssociation(sf) {
+			a, err := parseAssoc(i, sf)
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %w", sf.Name, err)
+			}
+
+			m.assocs = append(m.assocs, a)
+			continue
+		}
+
+		column := sf.Tag.Get(defaultTagName)
+		if column == "" || column == "-" {
+			continue
+		}
+
+		f := &fieldInfo{index: i, name: sf.Name, column: column, typ: sf.Type, nullable: isNullableType(sf.Type)}
+		if err := f.parseTag(sf.Tag.Get(ormTagName)); err != nil {
+			return nil, fmt.Errorf("field %s: %w", sf.Name, err)
+		}
+
+		m.fields = append(m.fields, f)
+	}
+
+	models.Store(t, m)
+	return m, nil
+}
+
+func (f *fieldInfo) parseTag(tag string) error {
+	for _, opt := range strings.Split(tag, ";") {
+		opt = strings.TrimSpace(opt)
+		if opt == "" {
+			continue
+		}
+
+		key, value := opt, ""
+		if i := strings.Index(opt, ":"); i >= 0 {
+			key, value = strings.TrimSpace(opt[:i]), strings.TrimSpace(opt[i+1:])
+		}
+
+		switch strings.ToLower(key) {
+		case "pk", "primary_key":
+			f.pk = true
+			f.nullable = false
+		case "auto_increment":
+			f.autoIncrement = true
+		case "null":
+			f.nullable = true
+		case "not null":
+			f.nullable = false
+		case "size":
+			size, err := strconv.Atoi(value)
+			if err != nil || size <= 0 {
+				return fmt.Errorf("invalid size %q", value)
+			}
+			f.size = size
+		case "default":
+			f.def = &value
+		case "type":
+			f.sqlType = value
+		case "index":
+			f.indexName = value
+			if value == "" {
+				f.indexName = "-"
+			}
+		case "unique":
+			f.uniqueName = value
+			if value == "" {
+				f.uniqueName = "-"
+			}
+		default:
+			return fmt.Errorf("unknown option %q", key)
+		}
+	}
+
+	return nil
+}
+
+// field 根据列名查找成员
+func (m *modelInfo) field(column string) *fieldInfo {
+	for _, f := range m.fields {
+		if f.column == column {
+			return f
+		}
+	}
+
+	return nil
+}
+
+// pk 返回主键成员
+func (m *modelInfo) pk() []*fieldInfo {
+	var pks []*fieldInfo
+	for _, f := range m.fields {
+		if f.pk {
+			pks = append(pks, f)
+		}
+	}
+
+	return pks
+}
+
+func isNullableType(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		return true
+	}
+
+	return t.PkgPath() == "database/sql" && strings.HasPrefix(t.Name(), "Null")
+}
+
+// toSnake 驼峰转蛇形，连续的大写字母视为一个单词，如 UserID 转换为 user_id
+func toSnake(name string) string {
+	runes := []rune(name)
+
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			if i > 0 && (unicode.IsLower(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]) && unicode.IsUpper(runes[i-1]))) {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
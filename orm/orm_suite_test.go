@@ -3,17 +3,23 @@ package orm_test
 import (
 	"database/sql"
 	"fmt"
+	"os"
 	"testing"
 
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 )
 
-var db *sql.DB
+// 设置该环境变量后运行依赖MySQL的用例，如 "root:@tcp(localhost:3306)/"，库名会被替换为 util
+const MySQLDSNEnv = "ORM_TEST_MYSQL_DSN"
+
+var (
+	db  *sql.DB
+	dsn string // 连接 util 库的DSN
+)
 
 const (
-	UseDatabase      = "USE util"
 	CreateDatabase   = "CREATE DATABASE IF NOT EXISTS util"
 	CreateTableSql   = "CREATE TABLE IF NOT EXISTS sample (id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY, user_id int NOT NULL, user_name VARCHAR(255) NOT NULL, city TEXT NOT NULL)"
 	InsertRowsFormat = `INSERT INTO sample (user_id, user_name, city) VALUES (%d, "%s", "%s")`
@@ -25,19 +31,40 @@ func TestOrm(t *testing.T) {
 	RunSpecs(t, "Orm Suite")
 }
 
+// requireMySQL 未设置 MySQLDSNEnv 时跳过当前用例
+func requireMySQL() {
+	if db == nil {
+		Skip("set " + MySQLDSNEnv + " to run specs against MySQL")
+	}
+}
+
 var _ = BeforeSuite(func() {
-	var err error
-	db, err = sql.Open("mysql", "root:@tcp(localhost:3306)/")
+	base := os.Getenv(MySQLDSNEnv)
+	if base == "" {
+		return
+	}
+
+	cfg, err := mysql.ParseDSN(base)
 	if err != nil {
 		panic(err.Error())
 	}
 
-	_, err = db.Exec(CreateDatabase)
+	cfg.DBName = ""
+	admin, err := sql.Open("mysql", cfg.FormatDSN())
 	if err != nil {
 		panic(err.Error())
 	}
 
-	_, err = db.Exec(UseDatabase)
+	_, err = admin.Exec(CreateDatabase)
+	admin.Close()
+	if err != nil {
+		panic(err.Error())
+	}
+
+	// 库名写在DSN中，连接池的每个连接都使用 util 库
+	cfg.DBName = "util"
+	dsn = cfg.FormatDSN()
+	db, err = sql.Open("mysql", dsn)
 	if err != nil {
 		panic(err.Error())
 	}
@@ -58,6 +85,10 @@ var _ = BeforeSuite(func() {
 })
 
 var _ = AfterSuite(func() {
+	if db == nil {
+		return
+	}
+
 	db.Exec(DropDatabase)
 	db.Close()
 })
@@ -0,0 +1,410 @@
+package ormtest
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+ * ormtest 提供内存中的 database/sql 驱动，用于在没有数据库服务的情况下测试 orm 及业务代码：
+ * 按顺序登记预期执行的语句及返回结果，实际执行的语句与预期不符时返回错误，并使 ExpectationsWereMet 失败
+ *  e.g
+ *  db, mock := ormtest.New()
+ *  defer db.Close()
+ *
+ *  mock.ExpectQuery("SELECT user_id, user_name FROM sample").WithArgs("beijing").
+ *      WillReturnRows(ormtest.NewRows("user_id", "user_name").AddRow(1000, "user_0"))
+ *  mock.ExpectExec("DELETE FROM sample").WillReturnResult(0, 1)
+ *
+ *  records, err := orm.Query(ctx, db, "SELECT user_id, user_name FROM sample WHERE city = ?", &UserInfo{}, "beijing")
+ *  _, err = orm.Exec(ctx, db, "DELETE FROM sample WHERE user_id = ?", 1000)
+ *
+ *  err = mock.ExpectationsWereMet()
+ *
+ * 语句匹配：预期语句与实际语句的连续空白均视为一个空格，实际语句包含预期语句即为匹配
+ */
+
+// 预期的操作类型
+const (
+	kindQuery    = "query"
+	kindExec     = "exec"
+	kindBegin    = "begin"
+	kindCommit   = "commit"
+	kindRollback = "rollback"
+)
+
+type anyArg struct{}
+
+// AnyArg 匹配任意参数，用于 WithArgs
+func AnyArg() interface{} {
+	return anyArg{}
+}
+
+// Expectation 一条预期，通过 Mock.ExpectXXX 创建
+type Expectation struct {
+	kind      string
+	query     string
+	args      []interface{}
+	checkArgs bool
+	rows      *Rows
+	result    driver.Result
+	err       error
+	delay     time.Duration
+	triggered bool
+}
+
+// WithArgs 设置预期的参数，未设置时不检查参数
+func (e *Expectation) WithArgs(args ...interface{}) *Expectation {
+	e.args = args
+	e.checkArgs = true
+	return e
+}
+
+// WillReturnRows 设置查询返回的数据，未设置时返回空结果
+func (e *Expectation) WillReturnRows(rows *Rows) *Expectation {
+	e.rows = rows
+	return e
+}
+
+// WillReturnResult 设置写入语句返回的自增ID及影响的行数
+func (e *Expectation) WillReturnResult(lastInsertID, rowsAffected int64) *Expectation {
+	e.result = &result{lastInsertID: lastInsertID, rowsAffected: rowsAffected}
+	return e
+}
+
+// WillReturnError 设置返回的错误
+func (e *Expectation) WillReturnError(err error) *Expectation {
+	e.err = err
+	return e
+}
+
+// WillDelayFor 设置执行耗时(毫秒)，期间ctx被取消时返回ctx的错误，用于测试超时
+func (e *Expectation) WillDelayFor(ms int) *Expectation {
+	e.delay = time.Duration(ms) * time.Millisecond
+	return e
+}
+
+func (e *Expectation) String() string {
+	if e.query == "" {
+		return e.kind
+	}
+
+	if e.checkArgs {
+		return fmt.Sprintf("%s %q with args %v", e.kind, e.query, e.args)
+	}
+
+	return fmt.Sprintf("%s %q", e.kind, e.query)
+}
+
+// Mock 登记预期并校验实际执行的语句，协程安全
+type Mock struct {
+	expectations []*Expectation
+	unexpected   []string // 与预期不符的实际操作
+	mutex        sync.Mutex
+}
+
+// New 创建数据库连接及对应的Mock，所有连接共享同一个Mock；Mock由连接池持有，连接池关闭后随之释放
+func New() (*sql.DB, *Mock) {
+	mock := &Mock{}
+	return sql.OpenDB(&connector{mock: mock}), mock
+}
+
+// ExpectQuery 预期执行查询语句
+func (m *Mock) ExpectQuery(query string) *Expectation {
+	return m.expect(kindQuery, query)
+}
+
+// ExpectExec 预期执行写入语句
+func (m *Mock) ExpectExec(query string) *Expectation {
+	return m.expect(kindExec, query)
+}
+
+// ExpectBegin 预期开启事务
+func (m *Mock) ExpectBegin() *Expectation {
+	return m.expect(kindBegin, "")
+}
+
+// ExpectCommit 预期提交事务
+func (m *Mock) ExpectCommit() *Expectation {
+	return m.expect(kindCommit, "")
+}
+
+// ExpectRollback 预期回滚事务
+func (m *Mock) ExpectRollback() *Expectation {
+	return m.expect(kindRollback, "")
+}
+
+// ExpectationsWereMet 检查是否所有预期都已执行，且没有执行过预期之外的操作
+func (m *Mock) ExpectationsWereMet() error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if len(m.unexpected) > 0 {
+		return fmt.Errorf("%d unexpected call(s): %s", len(m.unexpected), strings.Join(m.unexpected, "; "))
+	}
+
+	var unmet []string
+	for _, e := range m.expectations {
+		if !e.triggered {
+			unmet = append(unmet, e.String())
+		}
+	}
+
+	if len(unmet) > 0 {
+		return fmt.Errorf("%d expectation(s) were not met: %s", len(unmet), strings.Join(unmet, "; "))
+	}
+
+	return nil
+}
+
+func (m *Mock) expect(kind, query string) *Expectation {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	e := &Expectation{kind: kind, query: normalize(query)}
+	m.expectations = append(m.expectations, e)
+	return e
+}
+
+// match 按顺序取出下一条未执行的预期并与实际操作比较，不符时记录该操作
+func (m *Mock) match(ctx context.Context, kind, query string, args []driver.NamedValue) (*Expectation, error) {
+	next, err := m.next(kind, query, args)
+	if err != nil {
+		return nil, err
+	}
+
+	if next.delay > 0 {
+		timer := time.NewTimer(next.delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+
+	return next, next.err
+}
+
+// next 取出下一条未执行的预期并标记为已执行，与实际操作不符时记录到unexpected
+func (m *Mock) next(kind, query string, args []driver.NamedValue) (*Expectation, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	var next *Expectation
+	for _, e := range m.expectations {
+		if !e.triggered {
+			next = e
+			break
+		}
+	}
+
+	actual := kind
+	if query != "" {
+		actual = fmt.Sprintf("%s %q with args %v", kind, query, values(args))
+	}
+
+	var err error
+	if next == nil {
+		err = fmt.Errorf("unexpected %s: no expectation left", actual)
+	} else if next.kind != kind || !strings.Contains(normalize(query), next.query) {
+		err = fmt.Errorf("unexpected %s: next expectation is %s", actual, next)
+	} else if next.checkArgs {
+		if e := matchArgs(next.args, args); e != nil {
+			err = fmt.Errorf("unexpected %s: %v", actual, e)
+		}
+	}
+
+	if err != nil {
+		m.unexpected = append(m.unexpected, err.Error())
+		return nil, err
+	}
+
+	next.triggered = true
+	return next, nil
+}
+
+func matchArgs(expected []interface{}, actual []driver.NamedValue) error {
+	if len(expected) != len(actual) {
+		return fmt.Errorf("expected %d args, got %d", len(expected), len(actual))
+	}
+
+	for i, arg := range expected {
+		if _, ok := arg.(anyArg); ok {
+			continue
+		}
+
+		want, err := driver.DefaultParameterConverter.ConvertValue(arg)
+		if err != nil {
+			return fmt.Errorf("arg %d: %v", i, err)
+		}
+
+		got := actual[i].Value
+		if converted, err := driver.DefaultParameterConverter.ConvertValue(got); err == nil {
+			got = converted
+		}
+
+		if !reflect.DeepEqual(want, got) {
+			return fmt.Errorf("arg %d: expected %v, got %v", i, want, got)
+		}
+	}
+
+	return nil
+}
+
+func values(args []driver.NamedValue) []interface{} {
+	vs := make([]interface{}, 0, len(args))
+	for _, arg := range args {
+		vs = append(vs, arg.Value)
+	}
+
+	return vs
+}
+
+// normalize 连续空白替换为一个空格
+func normalize(query string) string {
+	return strings.Join(strings.Fields(query), " ")
+}
+
+// connector 持有Mock，不经过全局注册，连接池释放后Mock随之释放
+type connector struct {
+	mock *Mock
+}
+
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	return &conn{mock: c.mock}, nil
+}
+
+func (c *connector) Driver() driver.Driver {
+	return mockDriver{}
+}
+
+// mockDriver 只用于 connector.Driver，不支持通过dsn打开
+type mockDriver struct{}
+
+func (d mockDriver) Open(dsn string) (driver.Conn, error) {
+	return nil, errors.New("ormtest: use ormtest.New to open a mock database")
+}
+
+type conn struct {
+	mock *Mock
+}
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return &stmt{conn: c, query: query}, nil
+}
+
+func (c *conn) Close() error {
+	return nil
+}
+
+func (c *conn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if _, err := c.mock.match(ctx, kindBegin, "", nil); err != nil {
+		return nil, err
+	}
+
+	return &tx{conn: c}, nil
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	e, err := c.mock.match(ctx, kindQuery, query, args)
+	if err != nil {
+		return nil, err
+	}
+
+	if e.rows == nil {
+		return &rowsCursor{rows: NewRows()}, nil
+	}
+
+	return &rowsCursor{rows: e.rows}, nil
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	e, err := c.mock.match(ctx, kindExec, query, args)
+	if err != nil {
+		return nil, err
+	}
+
+	if e.result == nil {
+		return &result{}, nil
+	}
+
+	return e.result, nil
+}
+
+func (c *conn) Ping(ctx context.Context) error {
+	return nil
+}
+
+// CheckNamedValue 接受任意类型的参数，参数原样交给预期比较
+func (c *conn) CheckNamedValue(nv *driver.NamedValue) error {
+	return nil
+}
+
+type stmt struct {
+	conn  *conn
+	query string
+}
+
+func (s *stmt) Close() error {
+	return nil
+}
+
+func (s *stmt) NumInput() int {
+	return -1
+}
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.conn.ExecContext(context.Background(), s.query, named(args))
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.conn.QueryContext(context.Background(), s.query, named(args))
+}
+
+func named(args []driver.Value) []driver.NamedValue {
+	nvs := make([]driver.NamedValue, 0, len(args))
+	for i, arg := range args {
+		nvs = append(nvs, driver.NamedValue{Ordinal: i + 1, Value: arg})
+	}
+
+	return nvs
+}
+
+type tx struct {
+	conn *conn
+}
+
+func (t *tx) Commit() error {
+	_, err := t.conn.mock.match(context.Background(), kindCommit, "", nil)
+	return err
+}
+
+func (t *tx) Rollback() error {
+	_, err := t.conn.mock.match(context.Background(), kindRollback, "", nil)
+	return err
+}
+
+type result struct {
+	lastInsertID int64
+	rowsAffected int64
+}
+
+func (r *result) LastInsertId() (int64, error) {
+	return r.lastInsertID, nil
+}
+
+func (r *result) RowsAffected() (int64, error) {
+	return r.rowsAffected, nil
+}
@@ -0,0 +1,148 @@
+package ormtest_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"util/orm"
+	"util/orm/ormtest"
+)
+
+type UserInfo struct {
+	UserID   int    `db:"user_id"`
+	UserName string `db:"user_name"`
+	City     string `db:"city"`
+}
+
+type Member struct {
+	ID      int64  `db:"id" orm:"pk;auto_increment"`
+	Name    string `db:"name"`
+	Version int64  `db:"version"`
+}
+
+var _ = Describe("Mock", func() {
+	var db *sql.DB
+	var mock *ormtest.Mock
+
+	BeforeEach(func() {
+		db, mock = ormtest.New()
+	})
+
+	AfterEach(func() {
+		db.Close()
+	})
+
+	Context("query", func() {
+		It("should return canned rows", func() {
+			mock.ExpectQuery("SELECT user_id, user_name, city FROM sample WHERE city = ?").WithArgs("beijing").
+				WillReturnRows(ormtest.NewRows("user_id", "user_name", "city").
+					AddRow(1000, "user_0", "beijing").
+					AddRow(1004, "user_4", "beijing"))
+
+			records, err := orm.Query(context.TODO(), db, "SELECT user_id, user_name, city FROM sample WHERE city = ?", &UserInfo{}, "beijing")
+			Expect(err).Should(Succeed())
+			Expect(len(records) == 2).Should(BeTrue())
+			Expect(records[1].(*UserInfo).UserID == 1004).Should(BeTrue())
+			Expect(mock.ExpectationsWereMet()).Should(Succeed())
+		})
+
+		It("should reject unexpected query and args", func() {
+			mock.ExpectQuery("FROM sample").WithArgs("beijing")
+
+			_, err := orm.Query(context.TODO(), db, "SELECT * FROM sample WHERE city = ?", &UserInfo{}, "shanghai")
+			Expect(err).ShouldNot(Succeed())
+
+			_, err = orm.Query(context.TODO(), db, "SELECT * FROM account", &UserInfo{})
+			Expect(err).ShouldNot(Succeed())
+			Expect(mock.ExpectationsWereMet()).ShouldNot(Succeed())
+		})
+
+		It("should fail expectations after an unexpected statement", func() {
+			mock.ExpectExec("DELETE FROM sample").WillReturnResult(0, 1)
+
+			_, err := orm.Exec(context.TODO(), db, "UPDATE sample SET city = ?", "beijing")
+			Expect(err).ShouldNot(Succeed())
+
+			_, err = orm.Exec(context.TODO(), db, "DELETE FROM sample WHERE user_id = ?", 1000)
+			Expect(err).Should(Succeed())
+
+			err = mock.ExpectationsWereMet()
+			Expect(err).ShouldNot(Succeed())
+			Expect(strings.Contains(err.Error(), "UPDATE sample")).Should(BeTrue())
+		})
+
+		It("should return errors", func() {
+			mock.ExpectQuery("FROM sample").WillReturnError(sql.ErrConnDone)
+			mock.ExpectQuery("FROM sample").WillReturnRows(ormtest.NewRows("user_id", "user_name", "city").
+				AddRow(1000, "user_0", "beijing").
+				RowError(1, errors.New("broken row")))
+			mock.ExpectQuery("FROM sample").WithArgs(ormtest.AnyArg()).WillDelayFor(1000)
+
+			_, err := orm.Query(context.TODO(), db, "SELECT * FROM sample", &UserInfo{})
+			Expect(errors.Is(err, sql.ErrConnDone)).Should(BeTrue())
+
+			_, err = orm.Query(context.TODO(), db, "SELECT * FROM sample", &UserInfo{})
+			Expect(err).Should(MatchError("broken row"))
+
+			ctx, cancel := context.WithTimeout(context.TODO(), 10*time.Millisecond)
+			defer cancel()
+			_, err = orm.Query(ctx, db, "SELECT * FROM sample WHERE user_id = ?", &UserInfo{}, 1000)
+			Expect(errors.Is(err, context.DeadlineExceeded)).Should(BeTrue())
+		})
+
+		It("should report column types", func() {
+			mock.ExpectQuery("FROM sample").WillReturnRows(ormtest.NewRows("user_id", "created_at").
+				WithTypes("BIGINT", "DATETIME").
+				AddRow([]byte("1000"), []byte("2022-01-02 03:04:05")))
+
+			records, err := orm.QueryMaps(context.TODO(), db, "SELECT user_id, created_at FROM sample")
+			Expect(err).Should(Succeed())
+			Expect(records[0]["user_id"] == int64(1000)).Should(BeTrue())
+
+			_, ok := records[0]["created_at"].(time.Time)
+			Expect(ok).Should(BeTrue())
+		})
+	})
+
+	Context("exec and transaction", func() {
+		It("should return canned result", func() {
+			mock.ExpectExec("INSERT INTO member (name, version) VALUES (?, ?)").WithArgs("alice", 1).WillReturnResult(7, 1)
+
+			m := &Member{Name: "alice"}
+			Expect(orm.Insert(context.TODO(), db, m)).Should(Succeed())
+			Expect(m.ID == 7 && m.Version == 1).Should(BeTrue())
+			Expect(mock.ExpectationsWereMet()).Should(Succeed())
+		})
+
+		It("should report conflict with zero rows affected", func() {
+			mock.ExpectExec("UPDATE member").WillReturnResult(0, 0)
+
+			err := orm.Update(context.TODO(), db, &Member{ID: 7, Name: "bob", Version: 1})
+			Expect(errors.Is(err, orm.ErrConflict)).Should(BeTrue())
+		})
+
+		It("should commit and rollback", func() {
+			mock.ExpectBegin()
+			mock.ExpectExec("DELETE FROM sample").WillReturnResult(0, 1)
+			mock.ExpectCommit()
+
+			mock.ExpectBegin()
+			mock.ExpectExec("DELETE FROM sample").WillReturnError(errors.New("lock wait"))
+			mock.ExpectRollback()
+
+			fn := func(tx *sql.Tx) error {
+				_, err := tx.Exec("DELETE FROM sample WHERE user_id = ?", 1000)
+				return err
+			}
+
+			Expect(orm.WithTx(context.TODO(), db, nil, fn)).Should(Succeed())
+			Expect(orm.WithTx(context.TODO(), db, nil, fn)).ShouldNot(Succeed())
+			Expect(mock.ExpectationsWereMet()).Should(Succeed())
+		})
+	})
+})
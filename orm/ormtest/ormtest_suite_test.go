@@ -0,0 +1,13 @@
+package ormtest_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestOrmtest(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Ormtest Suite")
+}
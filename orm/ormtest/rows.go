@@ -0,0 +1,95 @@
+package ormtest
+
+import (
+	"database/sql/driver"
+	"io"
+)
+
+// Rows 查询返回的数据，可以被多条预期共用
+type Rows struct {
+	cols     []string
+	types    []string
+	values   [][]interface{}
+	rowErrs  map[int]error
+	closeErr error
+}
+
+// NewRows 创建查询结果，cols为列名
+func NewRows(cols ...string) *Rows {
+	return &Rows{cols: cols, rowErrs: make(map[int]error)}
+}
+
+// AddRow 添加一行数据，值的个数需要与列数一致，值的类型需要能够转换为 driver.Value（如 int、string、time.Time）
+func (r *Rows) AddRow(values ...interface{}) *Rows {
+	r.values = append(r.values, values)
+	return r
+}
+
+// WithTypes 设置各列的数据库类型名（如 VARCHAR、BIGINT），用于 ColumnTypes
+func (r *Rows) WithTypes(types ...string) *Rows {
+	r.types = types
+	return r
+}
+
+// RowError 读取第row行（从0开始）时返回err，用于测试迭代中途出错
+func (r *Rows) RowError(row int, err error) *Rows {
+	r.rowErrs[row] = err
+	return r
+}
+
+// CloseError 关闭结果集时返回err
+func (r *Rows) CloseError(err error) *Rows {
+	r.closeErr = err
+	return r
+}
+
+// rowsCursor 实现 driver.Rows，每次查询使用独立的读取位置
+type rowsCursor struct {
+	rows *Rows
+	pos  int
+}
+
+func (c *rowsCursor) Columns() []string {
+	return c.rows.cols
+}
+
+func (c *rowsCursor) Close() error {
+	return c.rows.closeErr
+}
+
+func (c *rowsCursor) Next(dest []driver.Value) error {
+	if err, ok := c.rows.rowErrs[c.pos]; ok {
+		return err
+	}
+
+	if c.pos >= len(c.rows.values) {
+		return io.EOF
+	}
+
+	row := c.rows.values[c.pos]
+	c.pos++
+
+	for i := range dest {
+		if i >= len(row) {
+			dest[i] = nil
+			continue
+		}
+
+		v, err := driver.DefaultParameterConverter.ConvertValue(row[i])
+		if err != nil {
+			return err
+		}
+		dest[i] = v
+	}
+
+	return nil
+}
+
+// ColumnTypeDatabaseTypeName 实现 driver.RowsColumnTypeDatabaseTypeName
+func (c *rowsCursor) ColumnTypeDatabaseTypeName(index int) string {
+	if index < len(c.rows.types) {
+		return c.rows.types[index]
+	}
+
+	return ""
+}
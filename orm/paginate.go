@@ -0,0 +1,201 @@
+package orm
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+/*
+ * 分页查询，支持两种方式：
+ *  1. Paginate 使用 LIMIT/OFFSET，同时返回总行数，适合页数较少、需要跳页的场景
+ *  2. PaginateKeyset 使用游标（上一页最后一行的排序键），翻页性能不随页数下降，适合无限滚动、数据导出等场景
+ *
+ *  e.g
+ *  b, _ := orm.SelectModel(&UserInfo{}, "db")
+ *  b.From("sample").Where("city = ?", "beijing")
+ *
+ *  page, err := orm.Paginate(ctx, db, b, &UserInfo{}, 1, 20)
+ *
+ *  keyset := orm.Keyset{Columns: []string{"user_id"}}
+ *  first, err := orm.PaginateKeyset(ctx, db, b, &UserInfo{}, keyset, "", 20)
+ *  second, err := orm.PaginateKeyset(ctx, db, b, &UserInfo{}, keyset, first.NextCursor, 20)
+ */
+
+// ErrInvalidCursor 游标格式错误或与排序键不匹配
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Page 分页结果
+type Page struct {
+	Records []interface{} `json:"records"`
+	Total   int64         `json:"total"` // 总行数
+	Page    int           `json:"page"`  // 当前页，从1开始
+	Size    int           `json:"size"`  // 每页行数
+	Pages   int           `json:"pages"` // 总页数
+}
+
+// Paginate 分页查询，b为查询构建器（不会被修改），modelPtr为数据对象的指针，page从1开始
+func Paginate(ctx context.Context, db Executor, b *SelectBuilder, modelPtr interface{}, page, size int) (*Page, error) {
+	if page < 1 || size < 1 {
+		return nil, errors.New("page and size must be positive")
+	}
+
+	var total int64
+	script, args := b.CountSQL()
+	rows, err := db.QueryContext(ctx, script, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	if rows.Next() {
+		err = rows.Scan(&total)
+	}
+	if closeErr := rows.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Page{Total: total, Page: page, Size: size, Pages: int((total + int64(size) - 1) / int64(size))}
+	if int64((page-1)*size) >= total {
+		return result, nil
+	}
+
+	script, args = b.clone().Limit(size).Offset((page - 1) * size).ToSQL()
+	if result.Records, err = Query(ctx, db, script, modelPtr, args...); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// Keyset 游标分页的排序键
+type Keyset struct {
+	Columns []string // 排序列，数据对象中需要有同名的"db"描述符；多列组合需要唯一，通常以主键结尾
+	Desc    bool     // 是否降序
+}
+
+// CursorPage 游标分页结果
+type CursorPage struct {
+	Records    []interface{} `json:"records"`
+	NextCursor string        `json:"next_cursor"` // 下一页游标，没有更多数据时为空
+	HasMore    bool          `json:"has_more"`
+}
+
+// PaginateKeyset 游标分页查询，b为查询构建器（不会被修改，也不要设置 OrderBy/Limit/Offset），
+// cursor为上一页返回的 NextCursor，首页传空字符串
+func PaginateKeyset(ctx context.Context, db Executor, b *SelectBuilder, modelPtr interface{}, keyset Keyset, cursor string, size int) (*CursorPage, error) {
+	if size < 1 {
+		return nil, errors.New("size must be positive")
+	}
+
+	if len(keyset.Columns) == 0 {
+		return nil, errors.New("keyset columns required")
+	}
+
+	m, err := parseModel(modelPtr)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make([]*fieldInfo, 0, len(keyset.Columns))
+	for _, col := range keyset.Columns {
+		f := m.field(col[strings.LastIndex(col, ".")+1:])
+		if f == nil {
+			return nil, fmt.Errorf("keyset column %s not found in model", col)
+		}
+		fields = append(fields, f)
+	}
+
+	q := b.clone()
+	q.orders = nil
+
+	dir, op := "ASC", ">"
+	if keyset.Desc {
+		dir, op = "DESC", "<"
+	}
+
+	for _, col := range keyset.Columns {
+		q.OrderBy(col + " " + dir)
+	}
+
+	if cursor != "" {
+		values, err := decodeCursor(cursor, fields)
+		if err != nil {
+			return nil, err
+		}
+
+		// 行值比较 (a, b) > (?, ?)，MySQL、PostgreSQL 及 SQLite 3.15+ 均支持
+		cols := strings.Join(keyset.Columns, ", ")
+		marks := strings.TrimSuffix(strings.Repeat("?, ", len(values)), ", ")
+		if len(values) == 1 {
+			q.Where(fmt.Sprintf("%s %s ?", cols, op), values...)
+		} else {
+			q.Where(fmt.Sprintf("(%s) %s (%s)", cols, op, marks), values...)
+		}
+	}
+
+	// 多取一行用于判断是否还有下一页
+	script, args := q.Limit(size + 1).Offset(-1).ToSQL()
+	records, err := Query(ctx, db, script, modelPtr, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &CursorPage{Records: records}
+	if len(records) > size {
+		page.Records = records[:size]
+		page.HasMore = true
+
+		if page.NextCursor, err = encodeCursor(page.Records[size-1], fields); err != nil {
+			return nil, err
+		}
+	}
+
+	return page, nil
+}
+
+func encodeCursor(record interface{}, fields []*fieldInfo) (string, error) {
+	v := reflect.Indirect(reflect.ValueOf(record))
+
+	values := make([]interface{}, 0, len(fields))
+	for _, f := range fields {
+		values = append(values, v.Field(f.index).Interface())
+	}
+
+	data, err := json.Marshal(values)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decodeCursor 解析游标，按排序列对应的成员类型还原参数
+func decodeCursor(cursor string, fields []*fieldInfo) ([]interface{}, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var raws []json.RawMessage
+	if err := json.Unmarshal(data, &raws); err != nil || len(raws) != len(fields) {
+		return nil, ErrInvalidCursor
+	}
+
+	values := make([]interface{}, 0, len(fields))
+	for i, f := range fields {
+		v := reflect.New(f.typ)
+		if err := json.Unmarshal(raws[i], v.Interface()); err != nil {
+			return nil, ErrInvalidCursor
+		}
+		values = append(values, v.Elem().Interface())
+	}
+
+	return values, nil
+}
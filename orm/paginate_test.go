@@ -0,0 +1,65 @@
+package orm_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"util/orm"
+)
+
+var _ = Describe("Paginate", func() {
+
+	BeforeEach(requireMySQL)
+
+	newBuilder := func() *orm.SelectBuilder {
+		b, err := orm.SelectModel(&UserInfo{}, "db")
+		Expect(err).Should(Succeed())
+		return b.From("sample").Where("city = ?", "shanghai").OrderBy("user_id")
+	}
+
+	Context("offset pagination", func() {
+		It("should be succeed", func() {
+			page, err := orm.Paginate(context.TODO(), db, newBuilder(), &UserInfo{}, 3, 10)
+			Expect(err).Should(Succeed())
+			Expect(page.Total == 25 && page.Pages == 3).Should(BeTrue())
+			Expect(len(page.Records) == 5).Should(BeTrue())
+			Expect(page.Records[0].(*UserInfo).UserID == 1081).Should(BeTrue())
+
+			page, err = orm.Paginate(context.TODO(), db, newBuilder(), &UserInfo{}, 4, 10)
+			Expect(err).Should(Succeed())
+			Expect(len(page.Records) == 0).Should(BeTrue())
+		})
+	})
+
+	Context("keyset pagination", func() {
+		It("should walk through all rows", func() {
+			keyset := orm.Keyset{Columns: []string{"user_id"}, Desc: true}
+
+			var ids []int
+			cursor := ""
+			for {
+				page, err := orm.PaginateKeyset(context.TODO(), db, newBuilder(), &UserInfo{}, keyset, cursor, 10)
+				Expect(err).Should(Succeed())
+
+				for _, r := range page.Records {
+					ids = append(ids, r.(*UserInfo).UserID)
+				}
+
+				if !page.HasMore {
+					break
+				}
+				cursor = page.NextCursor
+			}
+
+			Expect(len(ids) == 25).Should(BeTrue())
+			Expect(ids[0] == 1097 && ids[24] == 1001).Should(BeTrue())
+		})
+
+		It("should reject invalid cursor", func() {
+			keyset := orm.Keyset{Columns: []string{"city", "user_id"}}
+			_, err := orm.PaginateKeyset(context.TODO(), db, newBuilder(), &UserInfo{}, keyset, "bm90LWpzb24", 10)
+			Expect(err == orm.ErrInvalidCursor).Should(BeTrue())
+		})
+	})
+})
@@ -0,0 +1,113 @@
+package orm_test
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/go-sql-driver/mysql"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"util/orm"
+)
+
+var _ = Describe("Pool", func() {
+
+	Context("format dsn", func() {
+		It("should be succeed", func() {
+			cfg := &orm.PoolConfig{Host: "localhost", Port: 3306, User: "root", Database: "util",
+				Params: map[string]string{"parseTime": "true", "charset": "utf8mb4"}}
+
+			dsn, err := cfg.FormatDSN()
+			Expect(err).Should(Succeed())
+			Expect(dsn == "root@tcp(localhost:3306)/util?charset=utf8mb4&parseTime=true").Should(BeTrue())
+
+			cfg.Password = "p@ss:w/rd?"
+			dsn, err = cfg.FormatDSN()
+			Expect(err).Should(Succeed())
+
+			parsed, err := mysql.ParseDSN(dsn)
+			Expect(err).Should(Succeed())
+			Expect(parsed.User == "root" && parsed.Passwd == "p@ss:w/rd?" && parsed.Addr == "localhost:3306").Should(BeTrue())
+			Expect(parsed.DBName == "util" && parsed.ParseTime).Should(BeTrue())
+
+			cfg.Password = ""
+			cfg.Driver = "postgres"
+			cfg.Params = map[string]string{"sslmode": "disable"}
+			dsn, err = cfg.FormatDSN()
+			Expect(err).Should(Succeed())
+			Expect(dsn == "postgres://root:@localhost:3306/util?sslmode=disable").Should(BeTrue())
+
+			cfg.Driver = "sqlite3"
+			_, err = cfg.FormatDSN()
+			Expect(err).ShouldNot(Succeed())
+		})
+	})
+
+	Context("fail fast", func() {
+		BeforeEach(requireMySQL)
+
+		It("should refuse queries when saturated", func() {
+			pool, err := orm.Open(&orm.PoolConfig{DSN: dsn, MaxOpen: 1, FailFast: true, PingIntervalMS: -1})
+			Expect(err).Should(Succeed())
+			defer pool.Close()
+
+			rows, err := pool.QueryContext(context.TODO(), "SELECT user_id FROM sample")
+			Expect(err).Should(Succeed())
+
+			_, err = orm.Query(context.TODO(), pool, "SELECT * FROM sample", &UserInfo{})
+			Expect(err == orm.ErrPoolSaturated).Should(BeTrue())
+			Expect(rows.Close()).Should(Succeed())
+
+			// 只统计 BeforeSuite 写入的数据，其他用例会修改 sample 表
+			records, err := orm.Query(context.TODO(), pool, "SELECT user_id, user_name, city FROM sample WHERE user_id BETWEEN 1000 AND 1099", &UserInfo{})
+			Expect(err).Should(Succeed())
+			Expect(len(records) == 100).Should(BeTrue())
+
+			status := pool.Stats()
+			Expect(status.Rejected == 1 && status.Healthy && status.MaxOpen == 1).Should(BeTrue())
+
+			_, err = json.Marshal(status)
+			Expect(err).Should(Succeed())
+		})
+	})
+
+	Context("fail fast with SQLite", func() {
+		It("should hold the slot until rows closed or tx finished", func() {
+			pool, err := orm.Open(&orm.PoolConfig{Driver: "sqlite3", DSN: ":memory:", MaxOpen: 1, FailFast: true, PingIntervalMS: -1})
+			Expect(err).Should(Succeed())
+			defer pool.Close()
+
+			_, err = pool.ExecContext(context.TODO(), "CREATE TABLE notes (id INTEGER PRIMARY KEY, body TEXT)")
+			Expect(err).Should(Succeed())
+
+			rows, err := pool.QueryContext(context.TODO(), "SELECT id, body FROM notes")
+			Expect(err).Should(Succeed())
+
+			types, err := rows.ColumnTypes()
+			Expect(err).Should(Succeed())
+			Expect(types[0].DatabaseTypeName() == "INTEGER").Should(BeTrue())
+
+			_, err = pool.ExecContext(context.TODO(), "INSERT INTO notes (body) VALUES ('a')")
+			Expect(err == orm.ErrPoolSaturated).Should(BeTrue())
+			Expect(rows.Close()).Should(Succeed())
+
+			tx, err := pool.BeginTx(context.TODO(), nil)
+			Expect(err).Should(Succeed())
+
+			_, err = tx.ExecContext(context.TODO(), "INSERT INTO notes (body) VALUES ('a')")
+			Expect(err).Should(Succeed())
+
+			_, err = pool.QueryContext(context.TODO(), "SELECT id FROM notes")
+			Expect(err == orm.ErrPoolSaturated).Should(BeTrue())
+			Expect(tx.Commit()).Should(Succeed())
+
+			_, err = pool.QueryContext(context.TODO(), "SELECT * FROM not_exist_table")
+			Expect(err).ShouldNot(Succeed())
+
+			records, err := orm.QueryMaps(context.TODO(), pool, "SELECT id, body FROM notes")
+			Expect(err).Should(Succeed())
+			Expect(len(records) == 1 && pool.Stats().Rejected == 2).Should(BeTrue())
+		})
+	})
+})
@@ -0,0 +1,119 @@
+package orm
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+)
+
+/*
+ * Rows 游标式结果集迭代器，逐行读取数据，适用于无法一次性载入内存的大结果集
+ *  e.g
+ *  rows, err := orm.QueryRows(ctx, db, sql, args...)
+ *  if err != nil {
+ *      return err
+ *  }
+ *  defer rows.Close()
+ *
+ *  for rows.Next() {
+ *      var u UserInfo
+ *      if err := rows.Scan(&u); err != nil {
+ *          return err
+ *      }
+ *  }
+ *  return rows.Err()
+ */
+
+// Rows 结果集迭代器，非协程安全
+type Rows struct {
+	ctx  context.Context
+	rows *sql.Rows
+	err  error
+}
+
+// QueryRows 执行sql语句，返回结果集迭代器，使用完毕后需要调用Close；ctx被取消后迭代会终止
+func QueryRows(ctx context.Context, db Executor, sql string, args ...interface{}) (*Rows, error) {
+	rows, err := db.QueryContext(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Rows{ctx: ctx, rows: rows}, nil
+}
+
+// Next 移动到下一行，没有更多数据、发生错误或ctx被取消时返回false
+func (r *Rows) Next() bool {
+	if r.err != nil {
+		return false
+	}
+
+	if err := r.ctx.Err(); err != nil {
+		r.err = err
+		return false
+	}
+
+	return r.rows.Next()
+}
+
+// Scan 将当前行映射到dst，dst为数据对象的指针，成员顺序需要与查询的列顺序一致
+func (r *Rows) Scan(dst interface{}) error {
+	fields, err := GetColumns(dst)
+	if err != nil {
+		return err
+	}
+
+	return r.rows.Scan(fields...)
+}
+
+// Columns 返回结果集的列名
+func (r *Rows) Columns() ([]string, error) {
+	return r.rows.Columns()
+}
+
+// Err 返回迭代过程中发生的错误
+func (r *Rows) Err() error {
+	if r.err != nil {
+		return r.err
+	}
+
+	return r.rows.Err()
+}
+
+// Close 关闭结果集，可以重复调用
+func (r *Rows) Close() error {
+	return r.rows.Close()
+}
+
+// Each 执行sql语句，逐行将数据映射到新的数据对象后回调fn，modelPtr为数据对象的指针；
+// fn返回错误时终止迭代并返回该错误
+func Each(ctx context.Context, db Executor, sql string, modelPtr interface{}, fn func(record interface{}) error, args ...interface{}) (err error) {
+	if reflect.ValueOf(modelPtr).Kind() != reflect.Ptr {
+		return errors.New("need a pointer")
+	}
+
+	rows, err := QueryRows(ctx, db, sql, args...)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if closeErr := rows.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+
+	modelType := reflect.Indirect(reflect.ValueOf(modelPtr)).Type()
+	for rows.Next() {
+		record := reflect.New(modelType).Interface()
+		if err := rows.Scan(record); err != nil {
+			return err
+		}
+
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
@@ -0,0 +1,79 @@
+package orm_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"util/orm"
+)
+
+var _ = Describe("Rows", func() {
+
+	BeforeEach(requireMySQL)
+
+	Context("iterate rows", func() {
+		It("should be succeed", func() {
+			cols, err := orm.GetColNames(&UserInfo{}, "db")
+			Expect(err).Should(Succeed())
+
+			sql := fmt.Sprintf("SELECT %s FROM sample WHERE city = ? ORDER BY user_id", strings.Join(cols, ","))
+			rows, err := orm.QueryRows(context.TODO(), db, sql, "chengdu")
+			Expect(err).Should(Succeed())
+			defer rows.Close()
+
+			count := 0
+			for rows.Next() {
+				var u UserInfo
+				Expect(rows.Scan(&u)).Should(Succeed())
+				Expect(u.City).Should(Equal("chengdu"))
+				count++
+			}
+
+			Expect(rows.Err()).Should(Succeed())
+			Expect(count == 25).Should(BeTrue())
+		})
+
+		It("should stop when context canceled", func() {
+			ctx, cancel := context.WithCancel(context.TODO())
+			defer cancel()
+
+			rows, err := orm.QueryRows(ctx, db, "SELECT user_id, user_name, city FROM sample")
+			Expect(err).Should(Succeed())
+			defer rows.Close()
+
+			count := 0
+			for rows.Next() {
+				count++
+				if count == 10 {
+					cancel()
+				}
+			}
+
+			Expect(count >= 10 && count < 100).Should(BeTrue())
+			Expect(errors.Is(rows.Err(), context.Canceled)).Should(BeTrue())
+		})
+
+		It("each with callback", func() {
+			count := 0
+			stop := errors.New("stop")
+
+			err := orm.Each(context.TODO(), db, "SELECT user_id, user_name, city FROM sample", &UserInfo{}, func(record interface{}) error {
+				_, ok := record.(*UserInfo)
+				Expect(ok).Should(BeTrue())
+
+				count++
+				if count == 5 {
+					return stop
+				}
+				return nil
+			})
+
+			Expect(err == stop).Should(BeTrue())
+			Expect(count == 5).Should(BeTrue())
+		})
+	})
+})
@@ -2,9 +2,11 @@ package orm
 
 import (
 	"context"
-	"database/sql"
+	stdsql "database/sql"
 	"errors"
+	"fmt"
 	"reflect"
+	"strings"
 )
 
 // GetColNames 获取列名列表， modelPtr为数据对象的指针，tagName为成员描述符（即，"db", "gorm"等）
@@ -16,6 +18,9 @@ func GetColNames(modelPtr interface{}, tagName string) ([]string, error) {
 	var cols []string
 	t := reflect.TypeOf(modelPtr).Elem()
 	for i := 0; i < t.NumField(); i++ {
+		if isAssociation(t.Field(i)) {
+			continue
+		}
 		cols = append(cols, t.Field(i).Tag.Get(tagName))
 	}
 
@@ -31,39 +36,146 @@ func GetColumns(modelPtr interface{}) ([]interface{}, error) {
 	var cols []interface{}
 	value := reflect.ValueOf(modelPtr).Elem()
 	for i := 0; i < value.NumField(); i++ {
+		if isAssociation(value.Type().Field(i)) {
+			continue
+		}
 		cols = append(cols, value.Field(i).Addr().Interface())
 	}
 
 	return cols, nil
 }
 
-// Query 执行sql语句，modelPtr为数据对象的指针
-func Query(ctx context.Context, db *sql.DB, sql string, modelPtr interface{}) ([]interface{}, error) {
+// QueryOpts 查询选项
+type QueryOpts struct {
+	lenient  bool     // 宽松模式，跳过扫描失败的行
+	preloads []string // 需要加载的关联成员
+}
+
+// SetLenient 设置宽松模式：扫描失败的行会被跳过，错误收集到 ScanErrors 中与成功的记录一并返回；
+// 默认为严格模式，任意一行扫描失败即返回错误
+func (opts *QueryOpts) SetLenient() {
+	opts.lenient = true
+}
+
+// SetPreload 设置查询完成后需要加载的关联成员，fields为成员名，嵌套关联以 . 分隔，如 "Orders.Items"
+func (opts *QueryOpts) SetPreload(fields ...string) {
+	opts.preloads = append(opts.preloads, fields...)
+}
+
+// ScanError 记录某一行数据映射失败的信息，Row为行号（从0开始），Column为出错的列名，无法确定时为空
+type ScanError struct {
+	Row    int
+	Column string
+	Err    error
+}
+
+func (e *ScanError) Error() string {
+	if e.Column == "" {
+		return fmt.Sprintf("scan row %d: %v", e.Row, e.Err)
+	}
+
+	return fmt.Sprintf("scan row %d column %s: %v", e.Row, e.Column, e.Err)
+}
+
+func (e *ScanError) Unwrap() error {
+	return e.Err
+}
+
+// ScanErrors 宽松模式下收集的所有行错误
+type ScanErrors []*ScanError
+
+func (e ScanErrors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, se := range e {
+		msgs = append(msgs, se.Error())
+	}
+
+	return fmt.Sprintf("%d row(s) failed to scan: %s", len(e), strings.Join(msgs, "; "))
+}
+
+// Query 执行sql语句，modelPtr为数据对象的指针，args为sql语句中占位符对应的参数；
+// 任意一行扫描失败时返回 *ScanError
+func Query(ctx context.Context, db Executor, sql string, modelPtr interface{}, args ...interface{}) ([]interface{}, error) {
+	return QueryWith(ctx, db, QueryOpts{}, sql, modelPtr, args...)
+}
+
+// QueryWith 使用指定选项执行sql语句；宽松模式下若有行扫描失败，返回成功的记录及 ScanErrors
+func QueryWith(ctx context.Context, db Executor, opts QueryOpts, sql string, modelPtr interface{}, args ...interface{}) (records []interface{}, err error) {
 	if reflect.ValueOf(modelPtr).Kind() != reflect.Ptr {
 		return nil, errors.New("need a pointer")
 	}
 
-	rows, err := db.QueryContext(ctx, sql)
+	rows, err := QueryRows(ctx, db, sql, args...)
 	if err != nil {
 		return nil, err
 	}
 
 	defer func() {
-		err = rows.Close()
+		if closeErr := rows.Close(); closeErr != nil && err == nil {
+			records, err = nil, closeErr
+		}
 	}()
 
-	var records []interface{}
-	for rows.Next() {
-		cp := reflect.New(reflect.Indirect(reflect.ValueOf(modelPtr)).Type())
-		record := cp.Interface()
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
 
-		fields, _ := GetColumns(record)
-		if err := rows.Scan(fields...); err != nil {
+	var scanErrs ScanErrors
+	modelType := reflect.Indirect(reflect.ValueOf(modelPtr)).Type()
+	for i := 0; rows.Next(); i++ {
+		record := reflect.New(modelType).Interface()
+		if err := rows.Scan(record); err != nil {
+			scanErr := &ScanError{Row: i, Column: scanErrColumn(err, cols), Err: err}
+			if !opts.lenient {
+				return nil, scanErr
+			}
+
+			scanErrs = append(scanErrs, scanErr)
 			continue
 		}
 
 		records = append(records, record)
 	}
 
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(opts.preloads) > 0 {
+		if err := Preload(ctx, db, records, opts.preloads...); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(scanErrs) > 0 {
+		return records, scanErrs
+	}
+
 	return records, nil
 }
+
+// Exec 执行写入语句，执行成功后涉及的表的查询缓存失效
+func Exec(ctx context.Context, db Executor, sql string, args ...interface{}) (stdsql.Result, error) {
+	result, err := db.ExecContext(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	notifyExec(db, parseTables(sql)...)
+	return result, nil
+}
+
+// scanErrColumn 从 database/sql 的扫描错误中解析出错列名，格式为 "sql: Scan error on column index N, ..."
+func scanErrColumn(err error, cols []string) string {
+	var index int
+	if _, e := fmt.Sscanf(err.Error(), "sql: Scan error on column index %d", &index); e != nil {
+		return ""
+	}
+
+	if index < 0 || index >= len(cols) {
+		return ""
+	}
+
+	return cols[index]
+}
@@ -2,6 +2,7 @@ package orm_test
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -17,6 +18,8 @@ type UserInfo struct {
 
 var _ = Describe("Sql", func() {
 
+	BeforeEach(requireMySQL)
+
 	Context("insert data", func() {
 		It("should be succeed", func() {
 			cols, err := orm.GetColNames(&UserInfo{}, "db")
@@ -32,4 +35,31 @@ var _ = Describe("Sql", func() {
 			Expect(r.UserID == 1086).Should(BeTrue())
 		})
 	})
+
+	Context("scan error", func() {
+		const script = "SELECT user_id, IF(user_id = 1001, NULL, user_name), city FROM sample WHERE user_id IN (1000, 1001, 1002) ORDER BY user_id"
+
+		It("strict mode should return error with row and column", func() {
+			records, err := orm.Query(context.TODO(), db, script, &UserInfo{})
+			Expect(err).ShouldNot(Succeed())
+			Expect(records).Should(BeNil())
+
+			var scanErr *orm.ScanError
+			Expect(errors.As(err, &scanErr)).Should(BeTrue())
+			Expect(scanErr.Row == 1).Should(BeTrue())
+			Expect(scanErr.Column).ShouldNot(BeEmpty())
+		})
+
+		It("lenient mode should collect row errors", func() {
+			opts := orm.QueryOpts{}
+			opts.SetLenient()
+
+			records, err := orm.QueryWith(context.TODO(), db, opts, script, &UserInfo{})
+			Expect(len(records) == 2).Should(BeTrue())
+
+			var scanErrs orm.ScanErrors
+			Expect(errors.As(err, &scanErrs)).Should(BeTrue())
+			Expect(len(scanErrs) == 1 && scanErrs[0].Row == 1).Should(BeTrue())
+		})
+	})
 })
@@ -0,0 +1,179 @@
+package orm
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+/*
+ * WithTx 在事务中执行函数：函数返回nil时提交，返回错误或panic时回滚；
+ * db为 *sql.Tx 时使用保存点实现嵌套事务；遇到 MySQL 死锁或锁等待超时时，按退避策略重试整个函数
+ *  e.g
+ *  err := orm.WithTx(ctx, db, nil, func(tx *sql.Tx) error {
+ *      if _, err := tx.ExecContext(ctx, "UPDATE ..."); err != nil {
+ *          return err
+ *      }
+ *
+ *      return orm.WithTx(ctx, tx, nil, func(tx *sql.Tx) error { ... }) // 嵌套，使用保存点
+ *  })
+ */
+
+const (
+	defaultTxMaxRetries = 3
+	defaultTxBackoffMS  = 50
+
+	errLockDeadlock    = 1213 // ER_LOCK_DEADLOCK
+	errLockWaitTimeout = 1205 // ER_LOCK_WAIT_TIMEOUT
+)
+
+// Executor 执行sql语句的接口，*sql.DB、*sql.Tx、*sql.Conn 均实现了该接口，包中的查询、写入函数都接受Executor
+type Executor interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// TxBeginner 可以开启事务的Executor，*sql.DB 实现了该接口
+type TxBeginner interface {
+	Executor
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+}
+
+// TxOpts 事务选项
+type TxOpts struct {
+	txOptions  sql.TxOptions
+	maxRetries *int // 死锁重试次数
+	backoffMS  *int // 首次重试等待时间，之后每次翻倍
+}
+
+// SetIsolation 设置事务隔离级别
+func (opts *TxOpts) SetIsolation(level sql.IsolationLevel) {
+	opts.txOptions.Isolation = level
+}
+
+// SetReadOnly 设置只读事务
+func (opts *TxOpts) SetReadOnly() {
+	opts.txOptions.ReadOnly = true
+}
+
+// SetRetry 设置死锁重试次数及首次重试等待时间(毫秒)，maxRetries为0表示不重试
+func (opts *TxOpts) SetRetry(maxRetries, backoffMS int) {
+	opts.maxRetries = &maxRetries
+	opts.backoffMS = &backoffMS
+}
+
+var savepointSeq uint64
+
+// WithTx 在事务中执行fn，opts为nil时使用默认选项（默认隔离级别，死锁重试3次）；
+// db为 *sql.Tx 时fn在保存点中执行，仅回滚到保存点，且不做重试（由最外层事务重试）
+func WithTx(ctx context.Context, db Executor, opts *TxOpts, fn func(tx *sql.Tx) error) error {
+	if opts == nil {
+		opts = &TxOpts{}
+	}
+
+	if tx, ok := db.(*sql.Tx); ok {
+		return withSavepoint(ctx, tx, fn)
+	}
+
+	beginner, ok := db.(TxBeginner)
+	if !ok {
+		return errors.New("executor does not support transaction")
+	}
+
+	maxRetries, backoffMS := defaultTxMaxRetries, defaultTxBackoffMS
+	if opts.maxRetries != nil {
+		maxRetries = *opts.maxRetries
+	}
+
+	if opts.backoffMS != nil {
+		backoffMS = *opts.backoffMS
+	}
+
+	for attempt := 0; ; attempt++ {
+		err := runTx(ctx, beginner, &opts.txOptions, fn)
+		if err == nil || attempt >= maxRetries || !IsRetryable(err) {
+			return err
+		}
+
+		// 指数退避并加入随机抖动，避免冲突的事务同时重试
+		wait := time.Duration(backoffMS<<uint(attempt)) * time.Millisecond
+		if wait > 0 {
+			wait += time.Duration(rand.Int63n(int64(wait)/2 + 1))
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+func runTx(ctx context.Context, db TxBeginner, txOptions *sql.TxOptions, fn func(tx *sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, txOptions)
+	if err != nil {
+		return err
+	}
+
+	// 提交或回滚后再次通知查询缓存，事务期间缓存的数据可能已过期
+	trackTx(tx)
+	defer func() {
+		notifyWrite(untrackTx(tx)...)
+	}()
+
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+			panic(r)
+		}
+	}()
+
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func withSavepoint(ctx context.Context, tx *sql.Tx, fn func(tx *sql.Tx) error) error {
+	name := fmt.Sprintf("sp_%d", atomic.AddUint64(&savepointSeq, 1))
+	if _, err := tx.ExecContext(ctx, "SAVEPOINT "+name); err != nil {
+		return err
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+name)
+			panic(r)
+		}
+	}()
+
+	if err := fn(tx); err != nil {
+		if _, rbErr := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+name); rbErr != nil {
+			return fmt.Errorf("%w (rollback to savepoint: %v)", err, rbErr)
+		}
+		return err
+	}
+
+	_, err := tx.ExecContext(ctx, "RELEASE SAVEPOINT "+name)
+	return err
+}
+
+// IsRetryable 判断错误是否为可重试的 MySQL 死锁或锁等待超时错误
+func IsRetryable(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == errLockDeadlock || mysqlErr.Number == errLockWaitTimeout
+	}
+
+	return false
+}
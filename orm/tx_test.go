@@ -0,0 +1,100 @@
+package orm_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/go-sql-driver/mysql"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"util/orm"
+	"util/orm/ormtest"
+)
+
+func countCity(city string) int {
+	var n int
+	err := db.QueryRow("SELECT COUNT(*) FROM sample WHERE city = ?", city).Scan(&n)
+	Expect(err).Should(Succeed())
+	return n
+}
+
+var _ = Describe("Tx", func() {
+
+	Context("with tx", func() {
+		BeforeEach(requireMySQL)
+
+		It("should commit on success", func() {
+			err := orm.WithTx(context.TODO(), db, nil, func(tx *sql.Tx) error {
+				_, err := orm.BatchInsert(context.TODO(), tx, "sample", []UserInfo{{UserID: 7000, UserName: "tx", City: "tx_commit"}}, 0)
+				return err
+			})
+
+			Expect(err).Should(Succeed())
+			Expect(countCity("tx_commit") == 1).Should(BeTrue())
+		})
+
+		It("should rollback on error and panic", func() {
+			failed := errors.New("failed")
+			err := orm.WithTx(context.TODO(), db, nil, func(tx *sql.Tx) error {
+				_, err := tx.Exec(fmt.Sprintf(InsertRowsFormat, 7001, "tx", "tx_rollback"))
+				Expect(err).Should(Succeed())
+				return failed
+			})
+
+			Expect(err == failed).Should(BeTrue())
+			Expect(countCity("tx_rollback") == 0).Should(BeTrue())
+
+			Expect(func() {
+				orm.WithTx(context.TODO(), db, nil, func(tx *sql.Tx) error {
+					tx.Exec(fmt.Sprintf(InsertRowsFormat, 7002, "tx", "tx_rollback"))
+					panic("panic in tx")
+				})
+			}).Should(Panic())
+			Expect(countCity("tx_rollback") == 0).Should(BeTrue())
+		})
+
+		It("nested tx should rollback to savepoint", func() {
+			err := orm.WithTx(context.TODO(), db, nil, func(tx *sql.Tx) error {
+				_, err := tx.Exec(fmt.Sprintf(InsertRowsFormat, 7003, "outer", "tx_nested"))
+				Expect(err).Should(Succeed())
+
+				err = orm.WithTx(context.TODO(), tx, nil, func(tx *sql.Tx) error {
+					tx.Exec(fmt.Sprintf(InsertRowsFormat, 7004, "inner", "tx_nested"))
+					return errors.New("inner failed")
+				})
+				Expect(err).ShouldNot(Succeed())
+				return nil
+			})
+
+			Expect(err).Should(Succeed())
+			Expect(countCity("tx_nested") == 1).Should(BeTrue())
+		})
+	})
+
+	Context("without database", func() {
+		It("should retry on deadlock", func() {
+			mockDB, mock := ormtest.New()
+			defer mockDB.Close()
+
+			for i := 0; i < 3; i++ {
+				mock.ExpectBegin()
+				mock.ExpectRollback()
+			}
+
+			opts := &orm.TxOpts{}
+			opts.SetRetry(2, 1)
+
+			attempts := 0
+			err := orm.WithTx(context.TODO(), mockDB, opts, func(tx *sql.Tx) error {
+				attempts++
+				return fmt.Errorf("update: %w", &mysql.MySQLError{Number: 1213, Message: "Deadlock found"})
+			})
+
+			Expect(orm.IsRetryable(err)).Should(BeTrue())
+			Expect(attempts == 3).Should(BeTrue())
+			Expect(mock.ExpectationsWereMet()).Should(Succeed())
+		})
+	})
+})
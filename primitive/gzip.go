@@ -3,6 +3,7 @@ package primitive
 import (
 	"bytes"
 	"compress/gzip"
+	"io"
 	"io/ioutil"
 )
 
@@ -40,3 +41,13 @@ func Gunzip(data []byte) ([]byte, error) {
 	defer r.Close()
 	return ioutil.ReadAll(r)
 }
+
+// NewGzipWriter 创建流式gzip压缩写入器，压缩后的数据写入w，写入完毕后需要调用Close
+func NewGzipWriter(w io.Writer) io.WriteCloser {
+	return gzip.NewWriter(w)
+}
+
+// NewGunzipReader 创建流式gzip解压读取器，读取完毕后需要调用Close
+func NewGunzipReader(r io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
@@ -2,6 +2,8 @@ package primitive
 
 import (
 	"bytes"
+	"io/ioutil"
+
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 )
@@ -28,5 +30,26 @@ var _ = Describe("Gzip", func() {
 
 			Expect(bytes.Compare(data, data2) == 0).Should(BeTrue())
 		})
+
+		It("stream", func() {
+			var buff bytes.Buffer
+			gz := NewGzipWriter(&buff)
+			for i := 0; i < 100; i++ {
+				_, err := gz.Write([]byte("user_id,user_name,city\n"))
+				Expect(err).Should(Succeed())
+			}
+			Expect(gz.Close()).Should(Succeed())
+
+			data, err := Gunzip(buff.Bytes())
+			Expect(err).Should(Succeed())
+			Expect(len(data) == 100*len("user_id,user_name,city\n")).Should(BeTrue())
+
+			r, err := NewGunzipReader(bytes.NewReader(buff.Bytes()))
+			Expect(err).Should(Succeed())
+			data2, err := ioutil.ReadAll(r)
+			Expect(err).Should(Succeed())
+			Expect(r.Close()).Should(Succeed())
+			Expect(bytes.Compare(data, data2) == 0).Should(BeTrue())
+		})
 	})
 })
@@ -0,0 +1,60 @@
+package schedule
+
+import (
+	"context"
+	"time"
+)
+
+/*
+ * 任务实现 IContextTask 后，调度器调用 RunContext 代替 Run，传入的ctx在调度器 Shutdown 或执行超时后取消；
+ * 任务实现 ITimeoutTask 时按指定的时间限制每次执行，超时后通过 OnError 上报包装了 context.DeadlineExceeded 的错误。
+ * 只实现了 Run 的任务通过 ContextAdapter 以相同的方式执行，但ctx无法中断 Run，调度器会等待 Run 返回
+ *  e.g
+ *  func (t *SyncTask) RunContext(ctx context.Context, s *schedule.TimingSchedule) error {
+ *      req, _ := http.NewRequestWithContext(ctx, "GET", t.url, nil)
+ *      ...
+ *  }
+ *
+ *  func (t *SyncTask) TimeoutMS() int64 {
+ *      return 30 * 1e3
+ *  }
+ */
+
+// 接收 context 的任务接口
+type IContextTask interface {
+	RunContext(ctx context.Context, s *TimingSchedule) error // 返回的错误通过 OnError 上报
+}
+
+// 指定执行超时时间的任务接口
+type ITimeoutTask interface {
+	TimeoutMS() int64 // 每次执行的超时时间(毫秒)，不大于0时不限制
+}
+
+// ContextAdapter 将只实现了 Run 的任务适配为 IContextTask，ctx 不会传给 Run
+type ContextAdapter struct {
+	ITimingTask
+}
+
+// RunContext 实现 IContextTask
+func (a ContextAdapter) RunContext(ctx context.Context, s *TimingSchedule) error {
+	a.Run(s)
+	return nil
+}
+
+// AsContextTask 返回任务的 IContextTask 形式，任务没有实现 IContextTask 时使用 ContextAdapter 包装
+func AsContextTask(t ITimingTask) IContextTask {
+	if c, ok := t.(IContextTask); ok {
+		return c
+	}
+
+	return ContextAdapter{t}
+}
+
+// taskTimeout 任务每次执行的超时时间，0表示不限制
+func taskTimeout(t ITimingTask) time.Duration {
+	if tt, ok := t.(ITimeoutTask); ok && tt.TimeoutMS() > 0 {
+		return time.Duration(tt.TimeoutMS()) * time.Millisecond
+	}
+
+	return 0
+}
@@ -0,0 +1,90 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type CtxTask struct {
+	at        int64
+	timeoutMS int64
+	err       error
+	started   chan struct{}
+	errs      chan error
+}
+
+func (r *CtxTask) RunAt() int64 {
+	return r.at
+}
+
+func (r *CtxTask) Run(s *TimingSchedule) {
+	panic("should call RunContext")
+}
+
+func (r *CtxTask) RunContext(ctx context.Context, s *TimingSchedule) error {
+	close(r.started)
+	if r.err != nil {
+		return r.err
+	}
+
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (r *CtxTask) TimeoutMS() int64 {
+	return r.timeoutMS
+}
+
+func (r *CtxTask) OnError(err error) {
+	r.errs <- err
+}
+
+func NewCtxTask(timeoutMS int64, err error) *CtxTask {
+	return &CtxTask{at: time.Now().Unix() - 1, timeoutMS: timeoutMS, err: err, started: make(chan struct{}), errs: make(chan error, 1)}
+}
+
+var _ = Describe("Context", func() {
+	It("adapter", func() {
+		_, ok := AsContextTask(&DemoTask{}).(ContextAdapter)
+		Expect(ok).Should(BeTrue())
+
+		task := NewCtxTask(0, nil)
+		Expect(AsContextTask(task) == IContextTask(task)).Should(BeTrue())
+	})
+
+	It("should report timeout and error", func() {
+		s := NewTimingSchedule(2, 10)
+		s.Start()
+		defer s.Shutdown()
+
+		task := NewCtxTask(100, nil)
+		s.Push(NewIntervalTask(task, 60*1e3)) // 周期任务将ctx及超时时间传给被包装的任务
+
+		begin := time.Now()
+		err := <-task.errs
+		Expect(errors.Is(err, context.DeadlineExceeded)).Should(BeTrue())
+		Expect(time.Since(begin) < 500*time.Millisecond).Should(BeTrue())
+
+		failed := NewCtxTask(0, errors.New("failed"))
+		s.Push(failed)
+		Expect((<-failed.errs).Error() == "failed").Should(BeTrue())
+	})
+
+	It("should cancel running task on shutdown", func() {
+		s := NewTimingSchedule(2, 10)
+		s.Start()
+
+		task := NewCtxTask(0, nil)
+		s.Push(task)
+		<-task.started
+
+		begin := time.Now()
+		s.Shutdown()
+		Expect(time.Since(begin) < 500*time.Millisecond).Should(BeTrue())
+		Expect(len(task.errs) == 0).Should(BeTrue())
+	})
+})
@@ -0,0 +1,336 @@
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+ * CronSchedule 标准 cron 表达式，支持5个字段（分 时 日 月 周）或6个字段（秒 分 时 日 月 周）
+ *  *        任意值，日、周字段也可以使用 ?
+ *  a-b      范围
+ *  x/n      步长，x可以是 *、a 或 a-b，a/n 表示从a开始到最大值
+ *  a,b,c    列表
+ *  月份及星期可以使用英文缩写，如 JAN-DEC、SUN-SAT，星期中 0 与 7 均表示周日；
+ *  日与周同时指定时，满足任意一个即触发
+ *
+ *  预定义表达式：
+ *  @yearly (@annually)  每年1月1日 00:00:00
+ *  @monthly             每月1日 00:00:00
+ *  @weekly              每周日 00:00:00
+ *  @daily (@midnight)   每天 00:00:00
+ *  @hourly              每小时整点
+ *  @every <duration>    固定间隔，如 @every 5m、@every 1h30m
+ *
+ *  e.g
+ *  c, err := ParseCron("0 30 9 * * MON-FRI")   // 工作日 09:30:00
+ *  next := c.Next(time.Now())
+ */
+
+// cron 各字段的取值范围
+type cronBounds struct {
+	min, max int
+	names    map[string]int
+}
+
+var (
+	secondBounds = cronBounds{0, 59, nil}
+	minuteBounds = cronBounds{0, 59, nil}
+	hourBounds   = cronBounds{0, 23, nil}
+	domBounds    = cronBounds{1, 31, nil}
+	monthBounds  = cronBounds{1, 12, map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	dowBounds = cronBounds{0, 7, map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 0 1 1 *",
+	"@annually": "0 0 0 1 1 *",
+	"@monthly":  "0 0 0 1 * *",
+	"@weekly":   "0 0 0 * * 0",
+	"@daily":    "0 0 0 * * *",
+	"@midnight": "0 0 0 * * *",
+	"@hourly":   "0 0 * * * *",
+}
+
+// cron 表达式最多向后查找的年数，超过后认为不会再触发（如 2月30日）
+const cronSearchYears = 5
+
+// CronSchedule 解析后的 cron 表达式
+type CronSchedule struct {
+	second, minute, hour, dom, month, dow uint64 // 各字段的取值集合，第n位为1表示取值n
+
+	domStar, dowStar bool // 日、周字段是否以 * 开头
+
+	every time.Duration // @every 的间隔
+	expr  string
+}
+
+// ParseCron 解析 cron 表达式
+func ParseCron(expr string) (*CronSchedule, error) {
+	spec := strings.TrimSpace(expr)
+	if spec == "" {
+		return nil, errors.New("empty cron expression")
+	}
+
+	if strings.HasPrefix(spec, "@every ") {
+		d, err := time.ParseDuration(strings.TrimSpace(spec[len("@every "):]))
+		if err != nil {
+			return nil, fmt.Errorf("cron %q: %w", expr, err)
+		}
+		if d < time.Second {
+			return nil, fmt.Errorf("cron %q: interval must be at least 1s", expr)
+		}
+		return &CronSchedule{every: d, expr: expr}, nil
+	}
+
+	if strings.HasPrefix(spec, "@") {
+		macro, ok := cronMacros[strings.ToLower(spec)]
+		if !ok {
+			return nil, fmt.Errorf("cron %q: unknown macro", expr)
+		}
+		spec = macro
+	}
+
+	fields := strings.Fields(spec)
+	switch len(fields) {
+	case 5:
+		fields = append([]string{"0"}, fields...)
+	case 6:
+	default:
+		return nil, fmt.Errorf("cron %q: expected 5 or 6 fields, got %d", expr, len(fields))
+	}
+
+	c := &CronSchedule{expr: expr}
+
+	var err error
+	bounds := []cronBounds{secondBounds, minuteBounds, hourBounds, domBounds, monthBounds, dowBounds}
+	sets := []*uint64{&c.second, &c.minute, &c.hour, &c.dom, &c.month, &c.dow}
+	for i, field := range fields {
+		if *sets[i], err = parseCronField(field, bounds[i]); err != nil {
+			return nil, fmt.Errorf("cron %q: field %q: %w", expr, field, err)
+		}
+	}
+
+	// 星期中的 7 同 0，表示周日
+	if c.dow&(1<<7) != 0 {
+		c.dow = c.dow&^(1<<7) | 1
+	}
+
+	c.domStar = strings.HasPrefix(fields[3], "*") || fields[3] == "?"
+	c.dowStar = strings.HasPrefix(fields[5], "*") || fields[5] == "?"
+	return c, nil
+}
+
+// parseCronField 解析单个字段，返回取值集合
+func parseCronField(field string, b cronBounds) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(field, ",") {
+		if part == "" {
+			return 0, errors.New("empty list item")
+		}
+
+		rng, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step %q", part[i+1:])
+			}
+			rng, step = part[:i], n
+		}
+
+		var lo, hi int
+		switch {
+		case rng == "*" || rng == "?":
+			lo, hi = b.min, b.max
+		case strings.Contains(rng, "-"):
+			i := strings.Index(rng, "-")
+			var err error
+			if lo, err = parseCronValue(rng[:i], b); err != nil {
+				return 0, err
+			}
+			if hi, err = parseCronValue(rng[i+1:], b); err != nil {
+				return 0, err
+			}
+			if lo > hi {
+				return 0, fmt.Errorf("invalid range %q", rng)
+			}
+		default:
+			v, err := parseCronValue(rng, b)
+			if err != nil {
+				return 0, err
+			}
+			lo, hi = v, v
+			if strings.Contains(part, "/") {
+				hi = b.max
+			}
+		}
+
+		for v := lo; v <= hi; v += step {
+			set |= 1 << uint(v)
+		}
+	}
+
+	return set, nil
+}
+
+func parseCronValue(s string, b cronBounds) (int, error) {
+	if v, ok := b.names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q", s)
+	}
+
+	if v < b.min || v > b.max {
+		return 0, fmt.Errorf("value %d out of range [%d, %d]", v, b.min, b.max)
+	}
+
+	return v, nil
+}
+
+// String 返回原始表达式
+func (c *CronSchedule) String() string {
+	return c.expr
+}
+
+// Next 返回t之后（不包含t）的下一次触发时间，时区与t一致；不会再触发时返回零值
+func (c *CronSchedule) Next(t time.Time) time.Time {
+	if c.every > 0 {
+		return t.Add(c.every).Truncate(time.Second)
+	}
+
+	// 从下一秒开始查找
+	t = t.Add(time.Second - time.Duration(t.Nanosecond()))
+	loc := t.Location()
+	limit := t.Year() + cronSearchYears
+
+	for t.Year() <= limit {
+		if !has(c.month, int(t.Month())) {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+			continue
+		}
+
+		if !c.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+			continue
+		}
+
+		if !has(c.hour, t.Hour()) {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+			continue
+		}
+
+		if !has(c.minute, t.Minute()) {
+			t = t.Truncate(time.Minute).Add(time.Minute)
+			continue
+		}
+
+		if !has(c.second, t.Second()) {
+			t = t.Add(time.Second)
+			continue
+		}
+
+		return t
+	}
+
+	return time.Time{}
+}
+
+// dayMatches 日与周均有限制时满足任意一个即可，否则需要同时满足
+func (c *CronSchedule) dayMatches(t time.Time) bool {
+	domMatch := has(c.dom, t.Day())
+	dowMatch := has(c.dow, int(t.Weekday()))
+
+	if c.domStar || c.dowStar {
+		return domMatch && dowMatch
+	}
+
+	return domMatch || dowMatch
+}
+
+func has(set uint64, v int) bool {
+	return set&(1<<uint(v)) != 0
+}
+
+// CronOpts cron 任务选项
+type CronOpts struct {
+	lockKey *string
+}
+
+// SetLockKey 设置多个实例间互斥执行的标识，参见 ILockedTask
+func (opts *CronOpts) SetLockKey(key string) {
+	opts.lockKey = &key
+}
+
+// CronTask 按 cron 表达式周期执行的任务
+type CronTask struct {
+	cron    *CronSchedule
+	runAt   int64
+	fn      func(s *TimingSchedule)
+	onError func(err error)
+	lockKey string
+}
+
+// NewCronTask 创建 cron 任务，首次执行时间为当前时间之后的第一个触发时间；onError可以为nil
+//  e.g
+//  t, err := NewCronTask("@every 5m", func(s *TimingSchedule) { ... }, nil)
+//  s.Push(t)
+func NewCronTask(expr string, fn func(s *TimingSchedule), onError func(err error), opts ...CronOpts) (*CronTask, error) {
+	c, err := ParseCron(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &CronTask{cron: c, fn: fn, onError: onError}
+	if len(opts) > 0 && opts[0].lockKey != nil {
+		t.lockKey = *opts[0].lockKey
+	}
+	if !t.Next(time.Now()) {
+		return nil, fmt.Errorf("cron %q: never fires", expr)
+	}
+
+	return t, nil
+}
+
+// RunAt 实现 ITimingTask
+func (t *CronTask) RunAt() int64 {
+	return t.runAt
+}
+
+// Run 实现 ITimingTask
+func (t *CronTask) Run(s *TimingSchedule) {
+	t.fn(s)
+}
+
+// OnError 实现 ITimingTask
+func (t *CronTask) OnError(err error) {
+	if t.onError != nil {
+		t.onError(err)
+	}
+}
+
+// LockKey 实现 ILockedTask
+func (t *CronTask) LockKey() string {
+	return t.lockKey
+}
+
+// Next 实现 IRecurringTask
+func (t *CronTask) Next(now time.Time) bool {
+	next := t.cron.Next(now)
+	if next.IsZero() {
+		return false
+	}
+
+	t.runAt = next.Unix()
+	return true
+}
@@ -0,0 +1,73 @@
+package schedule
+
+import (
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Cron", func() {
+	base := time.Date(2022, 3, 15, 10, 20, 30, 0, time.Local) // 周二
+
+	next := func(expr string, t time.Time) time.Time {
+		c, err := ParseCron(expr)
+		Expect(err).Should(Succeed())
+		return c.Next(t)
+	}
+
+	Context("parse", func() {
+		It("should be succeed", func() {
+			Expect(next("* * * * *", base).Equal(time.Date(2022, 3, 15, 10, 21, 0, 0, time.Local))).Should(BeTrue())
+			Expect(next("*/15 * * * * *", base).Equal(time.Date(2022, 3, 15, 10, 20, 45, 0, time.Local))).Should(BeTrue())
+			Expect(next("0 30 9 * * MON-FRI", base).Equal(time.Date(2022, 3, 16, 9, 30, 0, 0, time.Local))).Should(BeTrue())
+			Expect(next("0 0 1,15 * *", base).Equal(time.Date(2022, 4, 1, 0, 0, 0, 0, time.Local))).Should(BeTrue())
+			Expect(next("0 12 * JAN,jun ?", base).Equal(time.Date(2022, 6, 1, 12, 0, 0, 0, time.Local))).Should(BeTrue())
+			Expect(next("0 0 * * 7", base).Equal(time.Date(2022, 3, 20, 0, 0, 0, 0, time.Local))).Should(BeTrue())
+			Expect(next("0 8-18/4 * * *", base).Equal(time.Date(2022, 3, 15, 12, 0, 0, 0, time.Local))).Should(BeTrue())
+			Expect(next("5/20 * * * *", base).Equal(time.Date(2022, 3, 15, 10, 25, 0, 0, time.Local))).Should(BeTrue())
+		})
+
+		It("should apply day-of-month or day-of-week", func() {
+			// 13日或周五
+			Expect(next("0 0 13 * FRI", base).Equal(time.Date(2022, 3, 18, 0, 0, 0, 0, time.Local))).Should(BeTrue())
+			Expect(next("0 0 30 2 *", base).IsZero()).Should(BeTrue())
+		})
+
+		It("should support macros", func() {
+			Expect(next("@daily", base).Equal(time.Date(2022, 3, 16, 0, 0, 0, 0, time.Local))).Should(BeTrue())
+			Expect(next("@hourly", base).Equal(time.Date(2022, 3, 15, 11, 0, 0, 0, time.Local))).Should(BeTrue())
+			Expect(next("@weekly", base).Equal(time.Date(2022, 3, 20, 0, 0, 0, 0, time.Local))).Should(BeTrue())
+			Expect(next("@monthly", base).Equal(time.Date(2022, 4, 1, 0, 0, 0, 0, time.Local))).Should(BeTrue())
+			Expect(next("@yearly", base).Equal(time.Date(2023, 1, 1, 0, 0, 0, 0, time.Local))).Should(BeTrue())
+			Expect(next("@every 1h30m", base).Equal(base.Add(90 * time.Minute))).Should(BeTrue())
+		})
+
+		It("should reject invalid expressions", func() {
+			for _, expr := range []string{"", "* * * *", "60 * * * *", "* * * * * * *", "5-1 * * * *", "*/0 * * * *", "@often", "@every 10ms", "* * * FOO *"} {
+				_, err := ParseCron(expr)
+				Expect(err).ShouldNot(Succeed())
+			}
+		})
+	})
+
+	Context("cron task", func() {
+		It("should be re-queued after each run", func() {
+			var count int32
+			t, err := NewCronTask("@every 1s", func(s *TimingSchedule) {
+				atomic.AddInt32(&count, 1)
+			}, nil)
+			Expect(err).Should(Succeed())
+
+			s := NewTimingSchedule(1, 1, t)
+			s.Start()
+
+			time.Sleep(5 * time.Second)
+			Expect(s.Len() == 1).Should(BeTrue())
+			s.Shutdown()
+
+			Expect(atomic.LoadInt32(&count) >= 2).Should(BeTrue())
+		})
+	})
+})
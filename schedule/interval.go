@@ -0,0 +1,220 @@
+package schedule
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+/*
+ * IntervalTask 将任意 ITimingTask 包装为按固定间隔重复执行的任务，下一次执行时间由调度器在每次执行后计算：
+ *  FixedRate   按计划时间计算，下一次执行时间 = 上一次计划时间 + 间隔，执行耗时不影响频率；
+ *              执行耗时超过间隔时跳过已错过的时间点
+ *  FixedDelay  按完成时间计算，下一次执行时间 = 上一次执行完成时间 + 间隔
+ *
+ *  e.g
+ *  opts := IntervalOpts{}
+ *  opts.SetMode(FixedDelay)
+ *  opts.SetMaxRuns(10)
+ *  opts.SetJitter(500)
+ *  s.Push(NewIntervalTask(task, 60*1e3, opts))  // 首次在 task.RunAt() 执行，之后每60s执行一次，最多10次
+ */
+
+// IntervalMode 周期任务下一次执行时间的计算方式
+type IntervalMode int
+
+const (
+	FixedRate  IntervalMode = iota // 固定频率
+	FixedDelay                     // 固定延迟
+)
+
+// IntervalOpts 周期任务选项
+type IntervalOpts struct {
+	mode     *IntervalMode
+	maxRuns  *int
+	endAt    *int64
+	jitterMS *int64
+	misfire  *MisfirePolicy
+	graceMS  int64
+}
+
+// SetMode 设置下一次执行时间的计算方式，默认为 FixedRate
+func (opts *IntervalOpts) SetMode(mode IntervalMode) {
+	opts.mode = &mode
+}
+
+// SetMaxRuns 设置最多执行的次数，0表示不限制；因错过执行时间被跳过或锁被其他实例持有而未执行的不计入次数
+func (opts *IntervalOpts) SetMaxRuns(n int) {
+	opts.maxRuns = &n
+}
+
+// SetEndAt 设置结束时间(unix秒)，下一次执行时间晚于结束时间时不再执行
+func (opts *IntervalOpts) SetEndAt(endAt int64) {
+	opts.endAt = &endAt
+}
+
+// SetJitter 设置随机延迟上限(毫秒)，每次执行时间随机推后 [0, jitterMS)，避免多个任务同时执行
+func (opts *IntervalOpts) SetJitter(jitterMS int64) {
+	opts.jitterMS = &jitterMS
+}
+
+// SetMisfire 设置错过执行时间的处理策略及宽限期(毫秒)，参见 IMisfireTask；
+// 未设置时使用被包装任务的策略（如果实现了 IMisfireTask）
+func (opts *IntervalOpts) SetMisfire(policy MisfirePolicy, graceMS int64) {
+	opts.misfire = &policy
+	opts.graceMS = graceMS
+}
+
+// IntervalTask 固定间隔重复执行的任务，实现了 IRecurringTask
+type IntervalTask struct {
+	task     ITimingTask
+	interval time.Duration
+
+	mode    IntervalMode
+	maxRuns int
+	endAt   int64
+	jitter  time.Duration
+
+	misfire *MisfirePolicy
+	graceMS int64
+
+	planned time.Time // 计划执行时间（不含随机延迟），FixedRate 以此为基准
+	runAt   time.Time // 实际执行时间
+	runs    int       // 实际执行的次数
+}
+
+// NewIntervalTask 创建周期任务，首次执行时间为 task.RunAt()（实现了 IPreciseTask 时为 RunAtNano），为0时为当前时间之后下一个间隔整数倍的时间；intervalMS为间隔(毫秒)
+func NewIntervalTask(task ITimingTask, intervalMS int64, opts ...IntervalOpts) *IntervalTask {
+	if intervalMS <= 0 {
+		intervalMS = 1
+	}
+
+	t := &IntervalTask{task: task, interval: time.Duration(intervalMS) * time.Millisecond, mode: FixedRate}
+	if len(opts) > 0 {
+		opt := opts[0]
+
+		if opt.mode != nil {
+			t.mode = *opt.mode
+		}
+
+		if opt.maxRuns != nil {
+			t.maxRuns = *opt.maxRuns
+		}
+
+		if opt.endAt != nil {
+			t.endAt = *opt.endAt
+		}
+
+		if opt.jitterMS != nil && *opt.jitterMS > 0 {
+			t.jitter = time.Duration(*opt.jitterMS) * time.Millisecond
+		}
+
+		t.misfire, t.graceMS = opt.misfire, opt.graceMS
+	}
+
+	// 对齐到间隔的整数倍，多个实例创建的相同任务的计划执行时间一致
+	t.planned = time.Now().Truncate(t.interval).Add(t.interval)
+	if at := runAtNano(task); at > 0 {
+		t.planned = time.Unix(0, at)
+	}
+	t.runAt = t.planned.Add(t.randJitter())
+
+	return t
+}
+
+// RunAt 实现 ITimingTask
+func (t *IntervalTask) RunAt() int64 {
+	return t.runAt.Unix()
+}
+
+// RunAtNano 实现 IPreciseTask
+func (t *IntervalTask) RunAtNano() int64 {
+	return t.runAt.UnixNano()
+}
+
+// Run 实现 ITimingTask
+func (t *IntervalTask) Run(s *TimingSchedule) {
+	t.runs++
+	t.task.Run(s)
+}
+
+// RunContext 实现 IContextTask，被包装的任务没有实现 IContextTask 时调用其 Run
+func (t *IntervalTask) RunContext(ctx context.Context, s *TimingSchedule) error {
+	t.runs++
+	return AsContextTask(t.task).RunContext(ctx, s)
+}
+
+// TimeoutMS 实现 ITimeoutTask，返回被包装的任务的超时时间
+func (t *IntervalTask) TimeoutMS() int64 {
+	if tt, ok := t.task.(ITimeoutTask); ok {
+		return tt.TimeoutMS()
+	}
+
+	return 0
+}
+
+// OnError 实现 ITimingTask
+func (t *IntervalTask) OnError(err error) {
+	t.task.OnError(err)
+}
+
+// Misfire 实现 IMisfireTask
+func (t *IntervalTask) Misfire() (MisfirePolicy, int64) {
+	if t.misfire != nil {
+		return *t.misfire, t.graceMS
+	}
+
+	if m, ok := t.task.(IMisfireTask); ok {
+		return m.Misfire()
+	}
+
+	return MisfireIgnore, 0
+}
+
+// LockKey 实现 ILockedTask，被包装的任务实现了 ILockedTask 时返回其 LockKey，否则为空
+func (t *IntervalTask) LockKey() string {
+	if l, ok := t.task.(ILockedTask); ok {
+		return l.LockKey()
+	}
+
+	return ""
+}
+
+// occurrence 实现 iOccurrenceTask，返回不含随机延迟的计划执行时间
+func (t *IntervalTask) occurrence() int64 {
+	return t.planned.UnixNano()
+}
+
+// Runs 实际执行的次数
+func (t *IntervalTask) Runs() int {
+	return t.runs
+}
+
+// Next 实现 IRecurringTask，now为本次执行完成的时间
+func (t *IntervalTask) Next(now time.Time) bool {
+	if t.maxRuns > 0 && t.runs >= t.maxRuns {
+		return false
+	}
+
+	if t.mode == FixedDelay {
+		t.planned = now.Add(t.interval)
+	} else {
+		t.planned = t.planned.Add(t.interval)
+		if !t.planned.After(now) {
+			// 跳过已错过的时间点
+			missed := now.Sub(t.planned)/t.interval + 1
+			t.planned = t.planned.Add(missed * t.interval)
+		}
+	}
+
+	t.runAt = t.planned.Add(t.randJitter())
+	return t.endAt <= 0 || t.runAt.Unix() <= t.endAt
+}
+
+func (t *IntervalTask) randJitter() time.Duration {
+	if t.jitter <= 0 {
+		return 0
+	}
+
+	return time.Duration(rand.Int63n(int64(t.jitter)))
+}
@@ -0,0 +1,98 @@
+package schedule
+
+import (
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type CountTask struct {
+	timestamp int64
+	count     int32
+}
+
+func (r *CountTask) RunAt() int64 {
+	return r.timestamp
+}
+
+func (r *CountTask) Run(s *TimingSchedule) {
+	atomic.AddInt32(&r.count, 1)
+}
+
+func (r *CountTask) OnError(err error) {
+
+}
+
+var _ = Describe("Interval", func() {
+	base := time.Date(2022, 3, 15, 10, 20, 30, 0, time.Local)
+
+	Context("next run time", func() {
+		It("fixed rate", func() {
+			t := NewIntervalTask(&CountTask{timestamp: base.Unix()}, 10*1e3)
+			Expect(t.RunAt() == base.Unix()).Should(BeTrue())
+
+			Expect(t.Next(base.Add(3 * time.Second))).Should(BeTrue())
+			Expect(t.RunAt() == base.Unix()+10).Should(BeTrue())
+
+			// 执行耗时超过间隔，跳过错过的时间点
+			Expect(t.Next(base.Add(35 * time.Second))).Should(BeTrue())
+			Expect(t.RunAt() == base.Unix()+40).Should(BeTrue())
+		})
+
+		It("fixed delay", func() {
+			opts := IntervalOpts{}
+			opts.SetMode(FixedDelay)
+
+			t := NewIntervalTask(&CountTask{timestamp: base.Unix()}, 10*1e3, opts)
+			Expect(t.Next(base.Add(3 * time.Second))).Should(BeTrue())
+			Expect(t.RunAt() == base.Unix()+13).Should(BeTrue())
+		})
+
+		It("max runs, end time and jitter", func() {
+			opts := IntervalOpts{}
+			opts.SetMaxRuns(2)
+			t := NewIntervalTask(&CountTask{timestamp: base.Unix()}, 10*1e3, opts)
+			t.Run(nil)
+			Expect(t.Next(base)).Should(BeTrue())
+
+			// 未执行（错过执行时间被跳过、锁被其他实例持有）的不计入次数
+			Expect(t.Next(base.Add(10 * time.Second))).Should(BeTrue())
+			t.Run(nil)
+			Expect(t.Next(base.Add(20 * time.Second))).ShouldNot(BeTrue())
+			Expect(t.Runs() == 2).Should(BeTrue())
+
+			opts = IntervalOpts{}
+			opts.SetEndAt(base.Unix() + 15)
+			t = NewIntervalTask(&CountTask{timestamp: base.Unix()}, 10*1e3, opts)
+			Expect(t.Next(base)).Should(BeTrue())
+			Expect(t.Next(base.Add(10 * time.Second))).ShouldNot(BeTrue())
+
+			opts = IntervalOpts{}
+			opts.SetJitter(5 * 1e3)
+			t = NewIntervalTask(&CountTask{timestamp: base.Unix()}, 10*1e3, opts)
+			for i := 1; i <= 10; i++ {
+				Expect(t.Next(base.Add(time.Duration(i*10-5) * time.Second))).Should(BeTrue())
+				Expect(t.RunAt() >= base.Unix()+int64(i*10) && t.RunAt() < base.Unix()+int64(i*10)+5).Should(BeTrue())
+			}
+		})
+	})
+
+	Context("schedule", func() {
+		It("should stop after max runs", func() {
+			task := &CountTask{timestamp: time.Now().Unix() - 1}
+
+			opts := IntervalOpts{}
+			opts.SetMaxRuns(2)
+			s := NewTimingSchedule(1, 1, NewIntervalTask(task, 1*1e3, opts))
+			s.Start()
+
+			time.Sleep(6 * time.Second)
+			Expect(s.Len() == 0).Should(BeTrue())
+			s.Shutdown()
+
+			Expect(atomic.LoadInt32(&task.count) == 2).Should(BeTrue())
+		})
+	})
+})
@@ -0,0 +1,123 @@
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+/*
+ * 多个实例运行相同的任务时，通过 Locker 保证每个任务的每一次执行只在一个实例上发生：
+ * 任务实现 ILockedTask 后，调度器执行前以 "LockKey@计划执行时间" 为键获取锁，获取失败说明其他实例已经执行，跳过本次。
+ * 各实例计算出的计划执行时间需要一致，各实例的时钟需要同步：cron 任务按表达式计算；
+ * 周期任务需要使用 FixedRate，首次执行时间默认对齐到间隔的整数倍，锁以不含随机延迟的计划时间为键；
+ * FixedDelay 周期任务按各实例的完成时间计算，无法互斥
+ *  e.g
+ *  locker := sqlstore.NewLocker(db)  // 或 schedule.NewMemoryLocker()
+ *  s := schedule.NewTimingSchedule(4, 1)
+ *  s.SetLocker(locker, 0)
+ *
+ *  opts := schedule.CronOpts{}
+ *  opts.SetLockKey("report.daily")
+ *  t, _ := schedule.NewCronTask("0 0 2 * * *", fn, nil, opts)
+ *  s.Push(t)
+ */
+
+// 默认锁的有效期10分钟，需要大于各实例间的时钟偏差及执行延迟
+const defaultLockTTLMS = 10 * 60 * 1e3
+
+// iOccurrenceTask 执行时间含随机延迟的任务，返回用于加锁的计划执行时间(unix纳秒)
+type iOccurrenceTask interface {
+	occurrence() int64
+}
+
+// 需要在多个实例间互斥执行的任务接口
+type ILockedTask interface {
+	LockKey() string // 任务在各实例间相同的标识，为空时不加锁
+}
+
+// Locker 锁接口，实现需要协程安全
+type Locker interface {
+	TryLock(key string, ttl time.Duration) (bool, error) // 获取key的锁，已被持有且未过期时返回false；锁在ttl后过期，不需要释放
+}
+
+// SetLocker 设置锁，ttlMS为锁的有效期(毫秒)，不大于0时为10分钟；需要在 Start 之前调用
+func (s *TimingSchedule) SetLocker(locker Locker, ttlMS int64) {
+	if ttlMS <= 0 {
+		ttlMS = defaultLockTTLMS
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.locker, s.lockTTL = locker, time.Duration(ttlMS)*time.Millisecond
+}
+
+// lock 获取本次执行的锁，返回是否执行；获取锁出错时调用任务的 OnError，不执行
+func (s *TimingSchedule) lock(e *taskEntry) bool {
+	t, ok := e.task.(ILockedTask)
+	if !ok {
+		return true
+	}
+
+	s.mutex.Lock()
+	locker, ttl := s.locker, s.lockTTL
+	s.mutex.Unlock()
+
+	key := t.LockKey()
+	if locker == nil || key == "" {
+		return true
+	}
+
+	at := e.at
+	if o, ok := e.task.(iOccurrenceTask); ok {
+		at = o.occurrence()
+	}
+
+	locked, err := locker.TryLock(key+"@"+strconv.FormatInt(at, 10), ttl)
+	if err != nil {
+		s.onError(e.task, fmt.Errorf("lock task %d: %w", e.id, err))
+		return false
+	}
+
+	return locked
+}
+
+// 每隔一段时间清理一次过期的锁
+const lockPurgeInterval = time.Minute
+
+// MemoryLocker 进程内的锁，用于测试或单进程内多个调度器
+type MemoryLocker struct {
+	locks     map[string]time.Time // key -> 过期时间
+	lastPurge time.Time
+	mutex     sync.Mutex
+}
+
+// NewMemoryLocker 创建进程内的锁
+func NewMemoryLocker() *MemoryLocker {
+	return &MemoryLocker{locks: make(map[string]time.Time), lastPurge: time.Now()}
+}
+
+// TryLock 实现 Locker
+func (l *MemoryLocker) TryLock(key string, ttl time.Duration) (bool, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := time.Now()
+	if now.Sub(l.lastPurge) >= lockPurgeInterval {
+		for k, expiresAt := range l.locks {
+			if !now.Before(expiresAt) {
+				delete(l.locks, k)
+			}
+		}
+		l.lastPurge = now
+	}
+
+	if expiresAt, ok := l.locks[key]; ok && now.Before(expiresAt) {
+		return false, nil
+	}
+
+	l.locks[key] = now.Add(ttl)
+	return true, nil
+}
@@ -0,0 +1,160 @@
+package schedule
+
+import (
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// lockedRuns 记录各实例上 LockedTask 的执行时间
+type lockedRuns struct {
+	times []time.Time
+	mutex sync.Mutex
+}
+
+func (r *lockedRuns) count() int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return len(r.times)
+}
+
+// minGap 相邻两次执行的最小间隔，同一时间点在多个实例上执行时接近0
+func (r *lockedRuns) minGap() time.Duration {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	gap := time.Duration(1<<63 - 1)
+	for i := 1; i < len(r.times); i++ {
+		if d := r.times[i].Sub(r.times[i-1]); d < gap {
+			gap = d
+		}
+	}
+
+	return gap
+}
+
+type LockedTask struct {
+	at   int64
+	runs *lockedRuns
+}
+
+func (r *LockedTask) RunAt() int64 {
+	return r.at / int64(time.Second)
+}
+
+func (r *LockedTask) RunAtNano() int64 {
+	return r.at
+}
+
+func (r *LockedTask) Run(s *TimingSchedule) {
+	r.runs.mutex.Lock()
+	r.runs.times = append(r.runs.times, time.Now())
+	r.runs.mutex.Unlock()
+}
+
+func (r *LockedTask) OnError(err error) {
+
+}
+
+func (r *LockedTask) LockKey() string {
+	return "locked"
+}
+
+var _ = Describe("Locker", func() {
+	It("memory locker", func() {
+		l := NewMemoryLocker()
+
+		ok, err := l.TryLock("a", 50*time.Millisecond)
+		Expect(err == nil && ok).Should(BeTrue())
+
+		ok, _ = l.TryLock("a", 50*time.Millisecond)
+		Expect(ok).Should(BeFalse())
+
+		ok, _ = l.TryLock("b", 50*time.Millisecond)
+		Expect(ok).Should(BeTrue())
+
+		time.Sleep(60 * time.Millisecond)
+		ok, _ = l.TryLock("a", 50*time.Millisecond)
+		Expect(ok).Should(BeTrue())
+	})
+
+	It("should fire each occurrence on one replica only", func() {
+		locker := NewMemoryLocker()
+		at := time.Now().Add(100 * time.Millisecond).UnixNano()
+
+		runs := &lockedRuns{}
+		unlocked := &CountTask{timestamp: time.Now().Unix() - 1}
+		for i := 0; i < 3; i++ {
+			s := NewTimingSchedule(2, 10)
+			s.SetLocker(locker, 0)
+
+			opts := IntervalOpts{}
+			opts.SetMaxRuns(5)
+			s.Push(NewIntervalTask(&LockedTask{at: at, runs: runs}, 50, opts))
+
+			// 未实现 ILockedTask 的任务在每个实例上都执行
+			s.Push(unlocked)
+
+			s.Start()
+			defer s.Shutdown()
+		}
+
+		// 每个实例只计入自己执行的次数，各时间点只在一个实例上执行
+		time.Sleep(500 * time.Millisecond)
+		Expect(runs.count() >= 5 && runs.minGap() > 25*time.Millisecond).Should(BeTrue())
+		Expect(atomic.LoadInt32(&unlocked.count) == 3).Should(BeTrue())
+	})
+
+	It("should align default first run across replicas", func() {
+		locker := NewMemoryLocker()
+
+		runs := &lockedRuns{}
+		for i := 0; i < 3; i++ {
+			s := NewTimingSchedule(2, 10)
+			s.SetLocker(locker, 0)
+
+			// 不指定首次执行时间，并设置随机延迟
+			opts := IntervalOpts{}
+			opts.SetMaxRuns(2)
+			opts.SetJitter(50)
+			s.Push(NewIntervalTask(&LockedTask{runs: runs}, 200, opts))
+
+			s.Start()
+			defer s.Shutdown()
+		}
+
+		time.Sleep(700 * time.Millisecond)
+		Expect(runs.count() >= 2 && runs.minGap() > 100*time.Millisecond).Should(BeTrue())
+	})
+
+	It("should not count occurrences held by another replica", func() {
+		locker := NewMemoryLocker()
+		at := time.Now().Add(100 * time.Millisecond).UnixNano()
+
+		// 其他实例持有前两个时间点的锁
+		for i := int64(0); i < 2; i++ {
+			ok, _ := locker.TryLock("locked@"+strconv.FormatInt(at+i*int64(50*time.Millisecond), 10), time.Second)
+			Expect(ok).Should(BeTrue())
+		}
+
+		s := NewTimingSchedule(2, 10)
+		s.SetLocker(locker, 0)
+
+		runs := &lockedRuns{}
+		opts := IntervalOpts{}
+		opts.SetMaxRuns(2)
+		task := NewIntervalTask(&LockedTask{at: at, runs: runs}, 50, opts)
+		s.Push(task)
+		s.Start()
+		defer s.Shutdown()
+
+		Eventually(func() int { return s.Len() }, "1s", "10ms").Should(Equal(0))
+		Expect(runs.count() == 2).Should(BeTrue())
+		Expect(runs.times[0].UnixNano() >= at+int64(100*time.Millisecond)).Should(BeTrue())
+	})
+})
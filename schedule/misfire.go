@@ -0,0 +1,129 @@
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+/*
+ * 任务实际执行时间晚于计划执行时间超过宽限期即为错过执行(misfire)，如进程停止期间到期的任务、工作协程繁忙时积压的任务；
+ * 任务实现 IMisfireTask 后按指定的策略处理，并上报 *MisfireError（设置了 SetMisfireHook 时调用钩子，否则调用任务的 OnError）
+ *  MisfireRunOnce  立即执行一次，周期任务错过的多次只执行一次
+ *  MisfireRunAll   立即执行，周期任务按计划时间逐次补执行错过的每一次
+ *  MisfireSkip     不执行本次，周期任务等待下一次执行时间，一次性任务不再执行
+ *  MisfireDrop     不执行，并从调度器中删除任务（包括周期任务）
+ *
+ *  e.g
+ *  opts := IntervalOpts{}
+ *  opts.SetMisfire(MisfireSkip, 5*1e3)  // 晚于计划时间5s以上时跳过本次
+ *  s.Push(NewIntervalTask(task, 60*1e3, opts))
+ */
+
+// MisfirePolicy 错过执行时间的处理策略
+type MisfirePolicy int
+
+const (
+	MisfireIgnore  MisfirePolicy = iota // 不检查，到期即执行（默认）
+	MisfireRunOnce                      // 立即执行一次
+	MisfireRunAll                       // 补执行错过的每一次
+	MisfireSkip                         // 跳过本次
+	MisfireDrop                         // 删除任务
+)
+
+// 默认宽限期1s
+const defaultMisfireGraceMS = 1 * 1e3
+
+// String 策略名称
+func (p MisfirePolicy) String() string {
+	switch p {
+	case MisfireIgnore:
+		return "ignore"
+	case MisfireRunOnce:
+		return "run_once"
+	case MisfireRunAll:
+		return "run_all"
+	case MisfireSkip:
+		return "skip"
+	case MisfireDrop:
+		return "drop"
+	}
+
+	return "unknown"
+}
+
+// 指定错过执行时间处理策略的任务接口
+type IMisfireTask interface {
+	Misfire() (policy MisfirePolicy, graceMS int64) // graceMS为宽限期(毫秒)，不大于0时为1s
+}
+
+// ErrMisfire 任务错过执行时间，可以通过 errors.Is 判断
+var ErrMisfire = errors.New("task misfired")
+
+// MisfireError 任务错过执行时间
+type MisfireError struct {
+	ID     TaskID
+	At     time.Time     // 计划执行时间
+	Late   time.Duration // 晚于计划执行时间的时长
+	Policy MisfirePolicy
+}
+
+func (e *MisfireError) Error() string {
+	return fmt.Sprintf("task %d misfired: scheduled at %s, %s late, policy %s", e.ID, e.At.Format(time.RFC3339Nano), e.Late, e.Policy)
+}
+
+func (e *MisfireError) Is(target error) bool {
+	return target == ErrMisfire
+}
+
+// SetMisfireHook 设置错过执行时间的上报钩子，设置后不再调用任务的 OnError；需要在 Start 之前调用
+func (s *TimingSchedule) SetMisfireHook(hook func(task ITimingTask, err *MisfireError)) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.misfireHook = hook
+}
+
+// misfire 检查任务是否错过执行时间，错过时上报并返回处理策略
+func (s *TimingSchedule) misfire(e *taskEntry) (MisfirePolicy, bool) {
+	t, ok := e.task.(IMisfireTask)
+	if !ok {
+		return MisfireIgnore, false
+	}
+
+	policy, graceMS := t.Misfire()
+	if policy == MisfireIgnore {
+		return policy, false
+	}
+
+	if graceMS <= 0 {
+		graceMS = defaultMisfireGraceMS
+	}
+
+	at := time.Unix(0, e.at)
+	late := time.Since(at)
+	if late <= time.Duration(graceMS)*time.Millisecond {
+		return policy, false
+	}
+
+	err := &MisfireError{ID: e.id, At: at, Late: late, Policy: policy}
+
+	s.mutex.Lock()
+	hook := s.misfireHook
+	s.mutex.Unlock()
+
+	if hook == nil {
+		s.onError(e.task, err)
+		return policy, true
+	}
+
+	func() {
+		defer func() {
+			recover()
+		}()
+
+		hook(e.task, err)
+	}()
+
+	return policy, true
+}
@@ -0,0 +1,113 @@
+package schedule
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type MisfireTask struct {
+	at     int64
+	policy MisfirePolicy
+	count  int32
+	errs   chan error
+}
+
+func (r *MisfireTask) RunAt() int64 {
+	return r.at
+}
+
+func (r *MisfireTask) Run(s *TimingSchedule) {
+	atomic.AddInt32(&r.count, 1)
+}
+
+func (r *MisfireTask) OnError(err error) {
+	r.errs <- err
+}
+
+func (r *MisfireTask) Misfire() (MisfirePolicy, int64) {
+	return r.policy, 500
+}
+
+var _ = Describe("Misfire", func() {
+	var s *TimingSchedule
+
+	BeforeEach(func() {
+		s = NewTimingSchedule(2, 10)
+		s.Start()
+	})
+
+	AfterEach(func() {
+		s.Shutdown()
+	})
+
+	It("should run once and report to OnError", func() {
+		task := &MisfireTask{at: time.Now().Unix() - 3, policy: MisfireRunOnce, errs: make(chan error, 1)}
+		s.Push(task)
+
+		err := <-task.errs
+		Expect(errors.Is(err, ErrMisfire)).Should(BeTrue())
+
+		var misfire *MisfireError
+		Expect(errors.As(err, &misfire)).Should(BeTrue())
+		Expect(misfire.Late > 2*time.Second && misfire.Policy == MisfireRunOnce).Should(BeTrue())
+
+		time.Sleep(50 * time.Millisecond)
+		Expect(atomic.LoadInt32(&task.count) == 1).Should(BeTrue())
+	})
+
+	It("should skip and report to hook", func() {
+		reported := make(chan *MisfireError, 1)
+		s.SetMisfireHook(func(task ITimingTask, err *MisfireError) {
+			reported <- err
+		})
+
+		task := &MisfireTask{at: time.Now().Unix() - 3, policy: MisfireSkip, errs: make(chan error, 1)}
+		id := s.Push(task)
+
+		err := <-reported
+		Expect(err.ID == id && err.Policy == MisfireSkip).Should(BeTrue())
+
+		time.Sleep(50 * time.Millisecond)
+		Expect(atomic.LoadInt32(&task.count) == 0 && len(task.errs) == 0).Should(BeTrue())
+
+		// 未超过宽限期的任务正常执行
+		task = &MisfireTask{at: time.Now().Unix() + 1, policy: MisfireSkip, errs: make(chan error, 1)}
+		s.Push(task)
+		time.Sleep(1100 * time.Millisecond)
+		Expect(atomic.LoadInt32(&task.count) == 1 && len(reported) == 0).Should(BeTrue())
+	})
+
+	It("recurring task", func() {
+		s.SetMisfireHook(func(task ITimingTask, err *MisfireError) {})
+
+		start := time.Now().Unix() - 2
+		runAll := &CountTask{timestamp: start}
+		opts := IntervalOpts{}
+		opts.SetMisfire(MisfireRunAll, 50)
+		s.Push(NewIntervalTask(runAll, 100, opts))
+
+		runOnce := &CountTask{timestamp: start}
+		opts = IntervalOpts{}
+		opts.SetMisfire(MisfireRunOnce, 50)
+		s.Push(NewIntervalTask(runOnce, 100, opts))
+
+		dropped := &CountTask{timestamp: start}
+		opts = IntervalOpts{}
+		opts.SetMisfire(MisfireDrop, 50)
+		id := s.Push(NewIntervalTask(dropped, 100, opts))
+
+		time.Sleep(150 * time.Millisecond)
+
+		// 错过的约20次全部补执行
+		Expect(atomic.LoadInt32(&runAll.count) >= 20).Should(BeTrue())
+		Expect(atomic.LoadInt32(&runOnce.count) <= 3).Should(BeTrue())
+
+		_, _, ok := s.Get(id)
+		Expect(ok).Should(BeFalse())
+		Expect(atomic.LoadInt32(&dropped.count) == 0).Should(BeTrue())
+	})
+})
@@ -0,0 +1,169 @@
+package sqlstore
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"util/orm"
+)
+
+/*
+ * Locker 基于数据库表的 schedule.Locker，表以锁的键为主键，插入成功或抢占已过期的行即获得锁；过期的行每分钟清理一次
+ *  e.g
+ *  locker := sqlstore.NewLocker(db)
+ *  if err := locker.CreateTable(ctx); err != nil {
+ *      ...
+ *  }
+ *  s.SetLocker(locker, 0)
+ */
+
+const (
+	defaultLockTable  = "schedule_lock"
+	lockPurgeInterval = time.Minute // 每隔一段时间清理一次过期的锁
+)
+
+// LockOpts 数据库锁选项
+type LockOpts struct {
+	table   *string
+	dialect *orm.Dialect
+	owner   *string
+	tmoMS   *int64
+}
+
+// SetTable 设置表名，默认为 schedule_lock
+func (opts *LockOpts) SetTable(table string) {
+	opts.table = &table
+}
+
+// SetDialect 设置数据库方言，默认为 orm.MySQL
+func (opts *LockOpts) SetDialect(dialect orm.Dialect) {
+	opts.dialect = &dialect
+}
+
+// SetOwner 设置当前实例的标识，默认为 "hostname:pid"
+func (opts *LockOpts) SetOwner(owner string) {
+	opts.owner = &owner
+}
+
+// SetTimeout 设置每次操作的超时时间(毫秒)，默认为5s
+func (opts *LockOpts) SetTimeout(tmoMS int64) {
+	opts.tmoMS = &tmoMS
+}
+
+// Locker 基于数据库表的锁
+type Locker struct {
+	db      orm.Executor
+	table   string
+	dialect orm.Dialect
+	owner   string
+	tmo     time.Duration
+
+	lastPurge time.Time
+	mutex     sync.Mutex
+}
+
+// NewLocker 创建数据库锁
+func NewLocker(db orm.Executor, opts ...LockOpts) *Locker {
+	l := &Locker{db: db, table: defaultLockTable, dialect: orm.MySQL, owner: defaultLockOwner(),
+		tmo: defaultStoreTmoMS * time.Millisecond, lastPurge: time.Now()}
+	if len(opts) > 0 {
+		opt := opts[0]
+
+		if opt.table != nil {
+			l.table = *opt.table
+		}
+
+		if opt.dialect != nil {
+			l.dialect = *opt.dialect
+		}
+
+		if opt.owner != nil {
+			l.owner = *opt.owner
+		}
+
+		if opt.tmoMS != nil && *opt.tmoMS > 0 {
+			l.tmo = time.Duration(*opt.tmoMS) * time.Millisecond
+		}
+	}
+
+	return l
+}
+
+// CreateTable 创建锁表，表已存在时不做处理
+func (l *Locker) CreateTable(ctx context.Context) error {
+	stmt := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (lock_key VARCHAR(191) NOT NULL PRIMARY KEY, owner VARCHAR(128) NOT NULL, expires_at BIGINT NOT NULL)",
+		l.dialect.Quote(l.table))
+
+	_, err := orm.Exec(ctx, l.db, stmt)
+	return err
+}
+
+// TryLock 实现 schedule.Locker；expires_at 为unix毫秒
+func (l *Locker) TryLock(key string, ttl time.Duration) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), l.tmo)
+	defer cancel()
+
+	now := time.Now()
+	if err := l.purge(ctx, now); err != nil {
+		return false, err
+	}
+
+	table := l.dialect.Quote(l.table)
+	expiresAt := now.Add(ttl).UnixNano() / int64(time.Millisecond)
+	nowMS := now.UnixNano() / int64(time.Millisecond)
+
+	// 抢占已过期的锁
+	stmt := fmt.Sprintf("UPDATE %s SET owner = ?, expires_at = ? WHERE lock_key = ? AND expires_at <= ?", table)
+	result, err := orm.Exec(ctx, l.db, l.dialect.Rebind(stmt), l.owner, expiresAt, key, nowMS)
+	if err != nil {
+		return false, err
+	}
+
+	if n, err := result.RowsAffected(); err == nil && n > 0 {
+		return true, nil
+	}
+
+	stmt = fmt.Sprintf("INSERT INTO %s (lock_key, owner, expires_at) VALUES (?, ?, ?)", table)
+	_, insertErr := orm.Exec(ctx, l.db, l.dialect.Rebind(stmt), key, l.owner, expiresAt)
+	if insertErr == nil {
+		return true, nil
+	}
+
+	// 各数据库主键冲突的错误不同，通过查询判断锁是否已被持有
+	stmt = fmt.Sprintf("SELECT lock_key FROM %s WHERE lock_key = ?", table)
+	records, err := orm.QueryMaps(ctx, l.db, l.dialect.Rebind(stmt), key)
+	if err != nil {
+		return false, err
+	}
+
+	if len(records) > 0 {
+		return false, nil
+	}
+
+	return false, insertErr
+}
+
+// purge 清理过期的锁
+func (l *Locker) purge(ctx context.Context, now time.Time) error {
+	l.mutex.Lock()
+	if now.Sub(l.lastPurge) < lockPurgeInterval {
+		l.mutex.Unlock()
+		return nil
+	}
+	l.lastPurge = now
+	l.mutex.Unlock()
+
+	stmt := fmt.Sprintf("DELETE FROM %s WHERE expires_at <= ?", l.dialect.Quote(l.table))
+	_, err := orm.Exec(ctx, l.db, l.dialect.Rebind(stmt), now.UnixNano()/int64(time.Millisecond))
+	return err
+}
+
+// defaultLockOwner 锁持有者的默认标识，格式为 "hostname:pid"
+func defaultLockOwner() string {
+	host, _ := os.Hostname()
+	return host + ":" + strconv.Itoa(os.Getpid())
+}
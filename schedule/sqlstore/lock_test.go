@@ -0,0 +1,44 @@
+package sqlstore
+
+import (
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"util/orm/ormtest"
+)
+
+var _ = Describe("Locker", func() {
+	It("should acquire by insert and reject held lock", func() {
+		db, mock := ormtest.New()
+		defer db.Close()
+
+		opts := LockOpts{}
+		opts.SetOwner("node-1")
+		l := NewLocker(db, opts)
+
+		mock.ExpectExec("UPDATE `schedule_lock` SET owner = ?, expires_at = ? WHERE lock_key = ? AND expires_at <= ?").
+			WithArgs("node-1", ormtest.AnyArg(), "report@100", ormtest.AnyArg()).WillReturnResult(0, 0)
+		mock.ExpectExec("INSERT INTO `schedule_lock` (lock_key, owner, expires_at) VALUES (?, ?, ?)").
+			WithArgs("report@100", "node-1", ormtest.AnyArg()).WillReturnResult(0, 1)
+
+		ok, err := l.TryLock("report@100", time.Minute)
+		Expect(err == nil && ok).Should(BeTrue())
+
+		mock.ExpectExec("UPDATE `schedule_lock`").WillReturnResult(0, 0)
+		mock.ExpectExec("INSERT INTO `schedule_lock`").WillReturnError(errors.New("duplicate entry"))
+		mock.ExpectQuery("SELECT lock_key FROM `schedule_lock` WHERE lock_key = ?").WithArgs("report@100").
+			WillReturnRows(ormtest.NewRows("lock_key").AddRow("report@100"))
+
+		ok, err = l.TryLock("report@100", time.Minute)
+		Expect(err == nil && !ok).Should(BeTrue())
+
+		// 抢占已过期的锁
+		mock.ExpectExec("UPDATE `schedule_lock`").WillReturnResult(0, 1)
+
+		ok, err = l.TryLock("report@200", time.Minute)
+		Expect(err == nil && ok).Should(BeTrue())
+		Expect(mock.ExpectationsWereMet()).Should(Succeed())
+	})
+})
@@ -0,0 +1,13 @@
+package sqlstore
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestSQLStore(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "SQLStore Suite")
+}
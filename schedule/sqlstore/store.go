@@ -0,0 +1,147 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"util/orm"
+	"util/schedule"
+)
+
+/*
+ * Store 基于数据库表的 schedule.TaskStore，多个进程不能共用同一张表
+ *  e.g
+ *  opts := sqlstore.StoreOpts{}
+ *  opts.SetTable("timing_task")
+ *  store := sqlstore.NewStore(db, opts)
+ *  if err := store.CreateTable(ctx); err != nil {
+ *      ...
+ *  }
+ *  s.SetStore(store)
+ */
+
+const (
+	defaultStoreTable  = "schedule_task"
+	defaultStoreTmoMS  = 5 * 1e3
+	storeSelectColumns = "id, %s, run_at, data" // 顺序与 TaskRecord 成员一致
+)
+
+// StoreOpts 数据库存储选项
+type StoreOpts struct {
+	table   *string
+	dialect *orm.Dialect
+	tmoMS   *int64
+}
+
+// SetTable 设置表名，默认为 schedule_task
+func (opts *StoreOpts) SetTable(table string) {
+	opts.table = &table
+}
+
+// SetDialect 设置数据库方言，默认为 orm.MySQL
+func (opts *StoreOpts) SetDialect(dialect orm.Dialect) {
+	opts.dialect = &dialect
+}
+
+// SetTimeout 设置每次操作的超时时间(毫秒)，默认为5s
+func (opts *StoreOpts) SetTimeout(tmoMS int64) {
+	opts.tmoMS = &tmoMS
+}
+
+// Store 基于数据库表的任务存储
+type Store struct {
+	db      orm.Executor
+	table   string
+	dialect orm.Dialect
+	tmo     time.Duration
+}
+
+// NewStore 创建数据库存储，db需要支持事务（如 *sql.DB）
+func NewStore(db orm.Executor, opts ...StoreOpts) *Store {
+	s := &Store{db: db, table: defaultStoreTable, dialect: orm.MySQL, tmo: defaultStoreTmoMS * time.Millisecond}
+	if len(opts) > 0 {
+		opt := opts[0]
+
+		if opt.table != nil {
+			s.table = *opt.table
+		}
+
+		if opt.dialect != nil {
+			s.dialect = *opt.dialect
+		}
+
+		if opt.tmoMS != nil && *opt.tmoMS > 0 {
+			s.tmo = time.Duration(*opt.tmoMS) * time.Millisecond
+		}
+	}
+
+	return s
+}
+
+// CreateTable 创建任务表，表已存在时不做处理
+func (s *Store) CreateTable(ctx context.Context) error {
+	blob := "BLOB"
+	if s.dialect == orm.PostgreSQL {
+		blob = "BYTEA"
+	}
+
+	stmt := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (id BIGINT NOT NULL PRIMARY KEY, %s VARCHAR(128) NOT NULL, run_at BIGINT NOT NULL, data %s)",
+		s.dialect.Quote(s.table), s.dialect.Quote("type"), blob)
+
+	_, err := orm.Exec(ctx, s.db, stmt)
+	return err
+}
+
+// Save 实现 schedule.TaskStore，先删除再插入，不依赖各数据库不同的 upsert 语法
+func (s *Store) Save(rec *schedule.TaskRecord) error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.tmo)
+	defer cancel()
+
+	return orm.WithTx(ctx, s.db, nil, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, s.deleteSQL(), rec.ID); err != nil {
+			return err
+		}
+
+		stmt := fmt.Sprintf("INSERT INTO %s (%s) VALUES (?, ?, ?, ?)", s.dialect.Quote(s.table), s.columns())
+		_, err := tx.ExecContext(ctx, s.dialect.Rebind(stmt), rec.ID, rec.Type, rec.At, rec.Data)
+		return err
+	})
+}
+
+// Delete 实现 schedule.TaskStore
+func (s *Store) Delete(id schedule.TaskID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.tmo)
+	defer cancel()
+
+	_, err := orm.Exec(ctx, s.db, s.deleteSQL(), id)
+	return err
+}
+
+// Load 实现 schedule.TaskStore
+func (s *Store) Load() ([]*schedule.TaskRecord, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.tmo)
+	defer cancel()
+
+	stmt := fmt.Sprintf("SELECT %s FROM %s ORDER BY id", s.columns(), s.dialect.Quote(s.table))
+	records, err := orm.Query(ctx, s.db, stmt, &schedule.TaskRecord{})
+	if err != nil {
+		return nil, err
+	}
+
+	recs := make([]*schedule.TaskRecord, 0, len(records))
+	for _, rec := range records {
+		recs = append(recs, rec.(*schedule.TaskRecord))
+	}
+
+	return recs, nil
+}
+
+func (s *Store) columns() string {
+	return fmt.Sprintf(storeSelectColumns, s.dialect.Quote("type"))
+}
+
+func (s *Store) deleteSQL() string {
+	return s.dialect.Rebind(fmt.Sprintf("DELETE FROM %s WHERE id = ?", s.dialect.Quote(s.table)))
+}
@@ -0,0 +1,38 @@
+package sqlstore
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"util/orm"
+	"util/orm/ormtest"
+	"util/schedule"
+)
+
+var _ = Describe("Store", func() {
+	It("should save, delete and load records", func() {
+		db, mock := ormtest.New()
+		defer db.Close()
+
+		opts := StoreOpts{}
+		opts.SetTable("timing_task")
+		opts.SetDialect(orm.PostgreSQL)
+		store := NewStore(db, opts)
+
+		mock.ExpectBegin()
+		mock.ExpectExec(`DELETE FROM "timing_task" WHERE id = $1`).WithArgs(uint64(7)).WillReturnResult(0, 0)
+		mock.ExpectExec(`INSERT INTO "timing_task" (id, "type", run_at, data) VALUES ($1, $2, $3, $4)`).
+			WithArgs(uint64(7), "stored", int64(100), []byte("a")).WillReturnResult(0, 1)
+		mock.ExpectCommit()
+		mock.ExpectExec(`DELETE FROM "timing_task" WHERE id = $1`).WithArgs(uint64(8)).WillReturnResult(0, 1)
+		mock.ExpectQuery(`SELECT id, "type", run_at, data FROM "timing_task" ORDER BY id`).
+			WillReturnRows(ormtest.NewRows("id", "type", "run_at", "data").AddRow(7, "stored", 100, []byte("a")))
+
+		Expect(store.Save(&schedule.TaskRecord{ID: 7, Type: "stored", At: 100, Data: []byte("a")})).Should(Succeed())
+		Expect(store.Delete(8)).Should(Succeed())
+
+		records, err := store.Load()
+		Expect(err).Should(Succeed())
+		Expect(len(records) == 1 && records[0].ID == 7 && records[0].At == 100 && string(records[0].Data) == "a").Should(BeTrue())
+		Expect(mock.ExpectationsWereMet()).Should(Succeed())
+	})
+})
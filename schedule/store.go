@@ -0,0 +1,369 @@
+package schedule
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+/*
+ * TaskStore 持久化调度器中尚未执行的任务，进程重启后设置相同的存储，通过 Restore 恢复；
+ * 只有实现了 IPersistentTask 的任务会被持久化，并且需要通过 RegisterTask 注册任务类型，恢复时按类型名创建任务
+ *  e.g
+ *  schedule.RegisterTask("mail", func(data []byte) (schedule.ITimingTask, error) {
+ *      t := &MailTask{}
+ *      return t, json.Unmarshal(data, t)
+ *  })
+ *
+ *  store, err := schedule.NewFileStore("./data/tasks")
+ *  defer store.Close()
+ *
+ *  s := schedule.NewTimingSchedule(4, 1)
+ *  s.SetStore(store)
+ *  if err := s.Restore(); err != nil {
+ *      ...  // 部分记录无法恢复，其余任务已恢复
+ *  }
+ *  s.Start()
+ */
+
+// 可持久化的任务接口
+type IPersistentTask interface {
+	TaskType() string             // 通过 RegisterTask 注册的任务类型名
+	MarshalTask() ([]byte, error) // 序列化任务，恢复时传给 TaskFactory
+}
+
+// TaskFactory 根据序列化的数据创建任务
+type TaskFactory func(data []byte) (ITimingTask, error)
+
+var (
+	taskTypes      = make(map[string]TaskFactory)
+	taskTypesMutex sync.RWMutex
+)
+
+// RegisterTask 注册任务类型，类型名重复或factory为nil时panic
+func RegisterTask(name string, factory TaskFactory) {
+	taskTypesMutex.Lock()
+	defer taskTypesMutex.Unlock()
+
+	if factory == nil {
+		panic("schedule: RegisterTask factory is nil")
+	}
+
+	if _, dup := taskTypes[name]; dup {
+		panic("schedule: RegisterTask called twice for task type " + name)
+	}
+
+	taskTypes[name] = factory
+}
+
+// newTask 按类型名创建任务
+func newTask(name string, data []byte) (ITimingTask, error) {
+	taskTypesMutex.RLock()
+	factory, ok := taskTypes[name]
+	taskTypesMutex.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown task type %q", name)
+	}
+
+	return factory(data)
+}
+
+// TaskRecord 持久化的任务
+type TaskRecord struct {
+	ID   TaskID `json:"id" db:"id"`
+	Type string `json:"type" db:"type"`
+	At   int64  `json:"at" db:"run_at"` // 执行时间，unix纳秒
+	Data []byte `json:"data" db:"data"`
+}
+
+// TaskStore 任务存储接口，实现需要协程安全
+type TaskStore interface {
+	Save(rec *TaskRecord) error   // 添加或更新任务
+	Delete(id TaskID) error       // 删除任务，任务不存在时返回nil
+	Load() ([]*TaskRecord, error) // 加载所有任务，按ID排序
+}
+
+const (
+	storeSnapshotFile       = "tasks.snapshot"
+	storeLogFile            = "tasks.log"
+	defaultCompactThreshold = 1024
+)
+
+// FileStoreOpts 文件存储选项
+type FileStoreOpts struct {
+	sync             bool
+	compactThreshold *int
+}
+
+// SetSync 每次写入后调用fsync，保证进程崩溃或掉电时不丢失已写入的任务
+func (opts *FileStoreOpts) SetSync() {
+	opts.sync = true
+}
+
+// SetCompactThreshold 设置日志条数超过n时生成快照并清空日志，默认为1024
+func (opts *FileStoreOpts) SetCompactThreshold(n int) {
+	opts.compactThreshold = &n
+}
+
+// storeOp 日志中的一条操作，Delete 为true时只有ID有效
+type storeOp struct {
+	Delete bool `json:"del,omitempty"`
+	TaskRecord
+}
+
+// FileStore 基于文件的任务存储：每次修改追加写入日志，日志过长时将全部任务写入快照并清空日志；
+// 打开时加载快照并重放日志，截断崩溃时未写完的最后一条日志，跳过无法解析的日志（之后的写入拼接在未写完的日志后）
+type FileStore struct {
+	dir              string
+	sync             bool
+	compactThreshold int
+
+	records map[TaskID]*TaskRecord
+	log     *os.File
+	logN    int   // 日志条数
+	logSize int64 // 日志中完整写入的字节数
+
+	mutex sync.Mutex
+}
+
+// NewFileStore 打开dir目录下的任务存储，目录不存在时创建；不再使用时需要调用Close
+func NewFileStore(dir string, opts ...FileStoreOpts) (*FileStore, error) {
+	f := &FileStore{dir: dir, compactThreshold: defaultCompactThreshold, records: make(map[TaskID]*TaskRecord)}
+	if len(opts) > 0 {
+		f.sync = opts[0].sync
+		if opts[0].compactThreshold != nil && *opts[0].compactThreshold > 0 {
+			f.compactThreshold = *opts[0].compactThreshold
+		}
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	if err := f.loadSnapshot(); err != nil {
+		return nil, err
+	}
+
+	log, err := os.OpenFile(filepath.Join(dir, storeLogFile), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := f.replay(log); err != nil {
+		log.Close()
+		return nil, err
+	}
+
+	f.log = log
+	return f, nil
+}
+
+func (f *FileStore) loadSnapshot() error {
+	file, err := os.Open(filepath.Join(f.dir, storeSnapshotFile))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var records []*TaskRecord
+	if err := json.NewDecoder(file).Decode(&records); err != nil {
+		return fmt.Errorf("load snapshot: %w", err)
+	}
+
+	for _, rec := range records {
+		f.records[rec.ID] = rec
+	}
+
+	return nil
+}
+
+// replay 重放日志，并截断未写完的最后一条日志，之后的写入从截断处开始；
+// 无法解析的日志通常是未写完的日志与之后写入的日志拼接而成，从中恢复最后一条完整的日志，无法恢复时跳过
+func (f *FileStore) replay(log *os.File) error {
+	r := bufio.NewReader(log)
+
+	var offset int64
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		offset += int64(len(line))
+		f.logN++
+
+		if op := parseOp(line); op != nil {
+			f.apply(op)
+		}
+	}
+
+	if err := log.Truncate(offset); err != nil {
+		return err
+	}
+
+	f.logSize = offset
+	_, err := log.Seek(offset, io.SeekStart)
+	return err
+}
+
+// parseOp 解析一条日志，失败时依次尝试从之后的每个 {" 开始解析，均失败时返回nil
+func parseOp(line []byte) *storeOp {
+	for start := 0; start >= 0; {
+		op := &storeOp{}
+		if err := json.Unmarshal(line[start:], op); err == nil {
+			return op
+		}
+
+		next := bytes.Index(line[start+1:], []byte(`{"`))
+		if next < 0 {
+			return nil
+		}
+		start += next + 1
+	}
+
+	return nil
+}
+
+func (f *FileStore) apply(op *storeOp) {
+	if op.Delete {
+		delete(f.records, op.ID)
+		return
+	}
+
+	rec := op.TaskRecord
+	f.records[rec.ID] = &rec
+}
+
+// Save 实现 TaskStore
+func (f *FileStore) Save(rec *TaskRecord) error {
+	return f.append(&storeOp{TaskRecord: *rec})
+}
+
+// Delete 实现 TaskStore
+func (f *FileStore) Delete(id TaskID) error {
+	f.mutex.Lock()
+	_, ok := f.records[id]
+	f.mutex.Unlock()
+
+	if !ok {
+		return nil
+	}
+
+	return f.append(&storeOp{Delete: true, TaskRecord: TaskRecord{ID: id}})
+}
+
+// Load 实现 TaskStore
+func (f *FileStore) Load() ([]*TaskRecord, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return f.sorted(), nil
+}
+
+// Close 关闭日志文件
+func (f *FileStore) Close() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return f.log.Close()
+}
+
+func (f *FileStore) sorted() []*TaskRecord {
+	records := make([]*TaskRecord, 0, len(f.records))
+	for _, rec := range f.records {
+		copied := *rec
+		records = append(records, &copied)
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].ID < records[j].ID
+	})
+
+	return records
+}
+
+func (f *FileStore) append(op *storeOp) error {
+	line, err := json.Marshal(op)
+	if err != nil {
+		return err
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	line = append(line, '\n')
+	if _, err := f.log.Write(line); err != nil {
+		// 丢弃写入了一部分的日志，避免与之后的日志拼接
+		if f.log.Truncate(f.logSize) == nil {
+			f.log.Seek(f.logSize, io.SeekStart)
+		}
+		return err
+	}
+	f.logSize += int64(len(line))
+
+	if f.sync {
+		if err := f.log.Sync(); err != nil {
+			return err
+		}
+	}
+
+	f.apply(op)
+	f.logN++
+
+	if f.logN >= f.compactThreshold {
+		return f.compact()
+	}
+
+	return nil
+}
+
+// compact 将全部任务写入快照后清空日志；先写临时文件再重命名，清空日志前崩溃时重放日志结果不变
+func (f *FileStore) compact() error {
+	tmp := filepath.Join(f.dir, storeSnapshotFile+".tmp")
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+
+	if err := json.NewEncoder(file).Encode(f.sorted()); err != nil {
+		file.Close()
+		return err
+	}
+
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp, filepath.Join(f.dir, storeSnapshotFile)); err != nil {
+		return err
+	}
+
+	if err := f.log.Truncate(0); err != nil {
+		return err
+	}
+
+	if _, err := f.log.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	f.logN, f.logSize = 0, 0
+	return nil
+}
@@ -0,0 +1,251 @@
+package schedule
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// storedFired 持久化任务的执行通知，恢复的任务由 TaskFactory 创建，只能通过包变量通知
+var storedFired = make(chan string, 16)
+
+type StoredTask struct {
+	At   int64  `json:"at"`
+	Name string `json:"name"`
+}
+
+func (r *StoredTask) RunAt() int64 {
+	return r.At / int64(time.Second)
+}
+
+func (r *StoredTask) RunAtNano() int64 {
+	return r.At
+}
+
+func (r *StoredTask) Run(s *TimingSchedule) {
+	storedFired <- r.Name
+}
+
+func (r *StoredTask) OnError(err error) {
+
+}
+
+func (r *StoredTask) TaskType() string {
+	return "stored"
+}
+
+func (r *StoredTask) MarshalTask() ([]byte, error) {
+	return json.Marshal(r)
+}
+
+func init() {
+	RegisterTask("stored", func(data []byte) (ITimingTask, error) {
+		t := &StoredTask{}
+		return t, json.Unmarshal(data, t)
+	})
+}
+
+// SlowStore 每次写入前等待，模拟存储的延迟
+type SlowStore struct {
+	TaskStore
+	delay time.Duration
+}
+
+func (r *SlowStore) Save(rec *TaskRecord) error {
+	time.Sleep(r.delay)
+	return r.TaskStore.Save(rec)
+}
+
+var _ = Describe("Store", func() {
+	var dir string
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "schedule")
+		Expect(err).Should(Succeed())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	Context("file store", func() {
+		It("should replay log and snapshot", func() {
+			opts := FileStoreOpts{}
+			opts.SetCompactThreshold(3)
+
+			store, err := NewFileStore(dir, opts)
+			Expect(err).Should(Succeed())
+
+			Expect(store.Save(&TaskRecord{ID: 1, Type: "stored", At: 100, Data: []byte("a")})).Should(Succeed())
+			Expect(store.Save(&TaskRecord{ID: 2, Type: "stored", At: 200, Data: []byte("b")})).Should(Succeed())
+			Expect(store.Save(&TaskRecord{ID: 1, Type: "stored", At: 150, Data: []byte("c")})).Should(Succeed()) // 生成快照
+			Expect(store.Delete(2)).Should(Succeed())
+			Expect(store.Delete(3)).Should(Succeed())
+			Expect(store.Save(&TaskRecord{ID: 3, Type: "stored", At: 300})).Should(Succeed())
+			Expect(store.Close()).Should(Succeed())
+
+			// 模拟写入日志时崩溃
+			f, err := os.OpenFile(filepath.Join(dir, storeLogFile), os.O_APPEND|os.O_WRONLY, 0644)
+			Expect(err).Should(Succeed())
+			f.WriteString(`{"id":4,"type":"sto`)
+			f.Close()
+
+			store, err = NewFileStore(dir)
+			Expect(err).Should(Succeed())
+			defer store.Close()
+
+			records, err := store.Load()
+			Expect(err).Should(Succeed())
+			Expect(len(records) == 2).Should(BeTrue())
+			Expect(records[0].ID == 1 && records[0].At == 150 && string(records[0].Data) == "c").Should(BeTrue())
+			Expect(records[1].ID == 3 && records[1].At == 300).Should(BeTrue())
+
+			Expect(store.Save(&TaskRecord{ID: 4, Type: "stored", At: 400})).Should(Succeed())
+			records, _ = store.Load()
+			Expect(len(records) == 3).Should(BeTrue())
+		})
+
+		It("should skip broken log lines", func() {
+			store, err := NewFileStore(dir)
+			Expect(err).Should(Succeed())
+			Expect(store.Save(&TaskRecord{ID: 1, Type: "stored", At: 100})).Should(Succeed())
+			Expect(store.Close()).Should(Succeed())
+
+			// 未写完的日志之后又追加了完整的日志
+			f, err := os.OpenFile(filepath.Join(dir, storeLogFile), os.O_APPEND|os.O_WRONLY, 0644)
+			Expect(err).Should(Succeed())
+			f.WriteString(`{"id":2,"type":"sto` + `{"id":3,"type":"stored","at":300}` + "\n")
+			f.WriteString("garbage\n")
+			f.WriteString(`{"id":4,"type":"stored","at":400}` + "\n")
+			f.Close()
+
+			store, err = NewFileStore(dir)
+			Expect(err).Should(Succeed())
+			defer store.Close()
+
+			records, _ := store.Load()
+			Expect(len(records) == 3).Should(BeTrue())
+			Expect(records[0].ID == 1 && records[1].ID == 3 && records[1].At == 300 && records[2].ID == 4).Should(BeTrue())
+
+			Expect(store.Delete(1)).Should(Succeed())
+			records, _ = store.Load()
+			Expect(len(records) == 2).Should(BeTrue())
+		})
+	})
+
+	Context("schedule", func() {
+		It("should restore pending tasks", func() {
+			store, err := NewFileStore(dir)
+			Expect(err).Should(Succeed())
+
+			s := NewTimingSchedule(2, 10)
+			Expect(s.SetStore(store)).Should(Succeed())
+
+			at := time.Now().Add(300 * time.Millisecond).UnixNano()
+			done := s.Push(&StoredTask{At: at, Name: "done"})
+			pending := s.Push(&StoredTask{At: time.Now().Add(time.Hour).UnixNano(), Name: "pending"})
+			cancelled := s.Push(&StoredTask{At: time.Now().Add(time.Hour).UnixNano(), Name: "cancelled"})
+			s.Push(&DemoTask{timestamp: time.Now().Add(time.Hour).Unix()}) // 不可持久化的任务只保存在内存中
+
+			Expect(s.Cancel(cancelled)).Should(BeTrue())
+			Expect(s.Reschedule(pending, time.Now().Add(2*time.Hour))).Should(BeTrue())
+
+			Expect(s.Restore()).Should(Succeed())
+			s.Start()
+			Expect(<-storedFired == "done").Should(BeTrue())
+			s.Shutdown()
+			store.Close()
+
+			store, err = NewFileStore(dir)
+			Expect(err).Should(Succeed())
+			defer store.Close()
+
+			records, _ := store.Load()
+			Expect(len(records) == 1 && records[0].ID == pending && done != pending).Should(BeTrue())
+
+			// 启动前加入的任务保持原有的ID，恢复的任务排在其后重新分配ID
+			s = NewTimingSchedule(2, 10, &StoredTask{At: time.Now().Add(200 * time.Millisecond).UnixNano(), Name: "initial"})
+			Expect(s.SetStore(store)).Should(Succeed())
+			id := s.Push(&StoredTask{At: time.Now().Add(time.Hour).UnixNano(), Name: "new"})
+			Expect(id == 2 && s.Len() == 2).Should(BeTrue())
+
+			Expect(s.Restore()).Should(Succeed())
+			s.Start()
+			defer s.Shutdown()
+			Expect(s.SetStore(store)).ShouldNot(Succeed())
+			Expect(s.Restore()).ShouldNot(Succeed())
+
+			task, _, ok := s.Get(id)
+			Expect(ok && task.(*StoredTask).Name == "new").Should(BeTrue())
+
+			// 恢复的任务保持调整后的执行时间
+			task, runAt, ok := s.Get(3)
+			Expect(ok && task.(*StoredTask).Name == "pending").Should(BeTrue())
+			Expect(runAt.After(time.Now().Add(time.Hour))).Should(BeTrue())
+			Expect(<-storedFired == "initial").Should(BeTrue())
+
+			Expect(s.Reschedule(3, time.Now())).Should(BeTrue())
+			Expect(<-storedFired == "pending").Should(BeTrue())
+
+			time.Sleep(50 * time.Millisecond)
+			records, _ = store.Load()
+			Expect(len(records) == 1 && records[0].ID == id).Should(BeTrue())
+		})
+
+		It("should not block on store latency", func() {
+			store, err := NewFileStore(dir)
+			Expect(err).Should(Succeed())
+			defer store.Close()
+
+			s := NewTimingSchedule(2, 10)
+			s.SetStore(&SlowStore{TaskStore: store, delay: 200 * time.Millisecond})
+			s.Start()
+
+			begin := time.Now()
+			var ids []TaskID
+			for i := 0; i < 3; i++ {
+				ids = append(ids, s.Push(&StoredTask{At: time.Now().Add(time.Hour).UnixNano(), Name: "slow"}))
+			}
+			_, _, ok := s.Get(ids[0])
+			Expect(ok && time.Since(begin) < 100*time.Millisecond).Should(BeTrue())
+
+			// Shutdown 等待队列中的写入完成
+			s.Shutdown()
+			records, _ := store.Load()
+			Expect(len(records) == 3).Should(BeTrue())
+		})
+
+		It("should skip unknown task type", func() {
+			store, err := NewFileStore(dir)
+			Expect(err).Should(Succeed())
+			defer store.Close()
+
+			data, _ := (&StoredTask{At: time.Now().Add(time.Hour).UnixNano(), Name: "known"}).MarshalTask()
+			Expect(store.Save(&TaskRecord{ID: 5, Type: "unknown"})).Should(Succeed())
+			Expect(store.Save(&TaskRecord{ID: 3, Type: "stored", Data: data})).Should(Succeed())
+
+			s := NewTimingSchedule(1, 1)
+			Expect(s.SetStore(store)).Should(Succeed())
+
+			err = s.Restore()
+			Expect(err != nil && strings.Contains(err.Error(), "task 5")).Should(BeTrue())
+
+			// 其余任务照常恢复，新ID大于存储中所有的ID
+			task, _, ok := s.Get(6)
+			Expect(ok && task.(*StoredTask).Name == "known").Should(BeTrue())
+
+			s.Start()
+			s.Shutdown()
+
+			records, _ := store.Load()
+			Expect(len(records) == 2 && records[0].ID == 5 && records[1].ID == 6).Should(BeTrue())
+		})
+	})
+})
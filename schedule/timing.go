@@ -2,6 +2,7 @@ package schedule
 
 import (
 	"container/heap"
+	"context"
 	"errors"
 	"fmt"
 	"strconv"
@@ -11,9 +12,15 @@ import (
 )
 
 /*
- * TimingSchedule 一个按照指定时间执行任务的调度器
+ * TimingSchedule 一个按照指定时间执行任务的调度器；任务实现 IPreciseTask 时按纳秒精度执行，
+ * 调度器在最早的任务到期时被唤醒，Push 更早的任务时重新计算唤醒时间。
+ * Push 返回任务ID，可以通过 Cancel、Reschedule、Get 取消、调整及查询尚未执行的任务。
+ * 通过 SetStore 设置存储后，实现了 IPersistentTask 的任务在加入、调整、执行及取消时由写入协程按顺序写入存储，参见 TaskStore
  */
 
+// TaskID 任务ID，由 Push 分配，从1开始
+type TaskID uint64
+
 // 调度器接收的任务接口
 type ITimingTask interface {
 	RunAt() int64
@@ -21,22 +28,55 @@ type ITimingTask interface {
 	OnError(err error)
 }
 
-type taskQueue []ITimingTask
+// 周期任务接口，每次执行完成后调度器调用 Next 更新下一次执行时间，并将任务重新加入调度器
+type IRecurringTask interface {
+	ITimingTask
+	Next(now time.Time) bool // 更新 RunAt 为now之后的下一次执行时间，返回false表示不再执行
+}
+
+// 高精度任务接口，实现后调度器使用 RunAtNano 代替 RunAt
+type IPreciseTask interface {
+	RunAtNano() int64 // 执行时间，unix纳秒
+}
+
+// runAtNano 返回任务的执行时间(unix纳秒)
+func runAtNano(t ITimingTask) int64 {
+	if p, ok := t.(IPreciseTask); ok {
+		return p.RunAtNano()
+	}
+
+	return t.RunAt() * int64(time.Second)
+}
+
+// taskEntry 调度器中的任务，at为加入调度器时确定的执行时间(unix纳秒)
+type taskEntry struct {
+	task      ITimingTask
+	at        int64
+	id        TaskID
+	index     int  // 在堆中的下标，不在堆中时为-1
+	cancelled bool // 周期任务执行期间被取消，执行完成后不再加入调度器
+}
+
+type taskQueue []*taskEntry
 
 func (q taskQueue) Len() int {
 	return len(q)
 }
 
 func (q taskQueue) Less(i, j int) bool {
-	return q[i].RunAt() < q[j].RunAt()
+	return q[i].at < q[j].at
 }
 
 func (q taskQueue) Swap(i, j int) {
 	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
 }
 
 func (q *taskQueue) Push(x interface{}) {
-	*q = append(*q, x.(ITimingTask))
+	e := x.(*taskEntry)
+	e.index = len(*q)
+	*q = append(*q, e)
 }
 
 func (q *taskQueue) Pop() interface{} {
@@ -44,16 +84,32 @@ func (q *taskQueue) Pop() interface{} {
 	n := len(old)
 
 	x := old[n-1]
+	x.index = -1
+	old[n-1] = nil
 	*q = old[0 : n-1]
 	return x
 }
 
 type TimingSchedule struct {
 	shutdown    chan struct{}
+	ctx         context.Context // Shutdown 时取消，执行中的任务的ctx均派生自此
+	cancel      context.CancelFunc
+	wake        chan struct{}   // Push 了更早的任务时通知调度协程
+	ready       chan *taskEntry // 已到期的任务
 	workerCount int
 	intervalS   int
 
-	tasks taskQueue
+	tasks   taskQueue
+	entries map[TaskID]*taskEntry // 等待执行的任务及执行中的周期任务
+	nextID  TaskID
+	store   TaskStore
+	writes  []storeWrite  // 待写入存储的操作
+	written chan struct{} // 有新的写入操作时通知写入协程
+	started bool
+
+	misfireHook func(task ITimingTask, err *MisfireError)
+	locker      Locker
+	lockTTL     time.Duration
 
 	mutex sync.Mutex
 	wg    sync.WaitGroup
@@ -61,6 +117,8 @@ type TimingSchedule struct {
 
 // Len 查看调度器中的任务数量
 func (s *TimingSchedule) Len() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
 	return len(s.tasks)
 }
 
@@ -77,96 +135,526 @@ func (s *TimingSchedule) IsShutdown() bool {
 	return isShutdown
 }
 
-// Push 向调度器中添加任务，如果调度器处于关闭状态，Push无效
-func (s *TimingSchedule) Push(t ITimingTask) {
+// Push 向调度器中添加任务，返回任务ID；如果调度器处于关闭状态，Push无效，返回0
+func (s *TimingSchedule) Push(t ITimingTask) TaskID {
 	if s.IsShutdown() {
+		return 0
+	}
+
+	s.mutex.Lock()
+	s.nextID++
+	e := &taskEntry{task: t, at: runAtNano(t), id: s.nextID}
+	s.entries[e.id] = e
+	s.save(e)
+	earliest := s.push(e)
+	s.mutex.Unlock()
+
+	if earliest {
+		s.notify()
+	}
+
+	return e.id
+}
+
+// push 将任务加入堆，返回任务是否成为最早执行的任务，调用方需要持有锁
+func (s *TimingSchedule) push(e *taskEntry) bool {
+	heap.Push(&s.tasks, e)
+	return e.index == 0
+}
+
+// SetStore 设置任务存储，存储中尚未执行的任务通过 Restore 恢复；需要在 Start 之前调用。
+// 已加入的任务保持原有的ID并写入存储
+func (s *TimingSchedule) SetStore(store TaskStore) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.started {
+		return errors.New("SetStore must be called before Start")
+	}
+
+	s.store = store
+	for _, e := range s.tasks {
+		s.save(e)
+	}
+
+	return nil
+}
+
+// Restore 恢复存储中尚未执行的任务，需要在 SetStore 之后、Start 之前调用；恢复的任务重新分配ID，
+// 新ID大于存储中所有的ID，每个任务先以新ID写入再删除原记录，写入过程中进程退出时任务可能被重复恢复，但不会丢失。
+// 无法恢复的记录（如任务类型未注册）保留在存储中并跳过，其余任务照常恢复，返回的错误包含所有跳过的记录
+func (s *TimingSchedule) Restore() error {
+	s.mutex.Lock()
+	store, started := s.store, s.started
+	s.mutex.Unlock()
+
+	if store == nil {
+		return errors.New("store not set")
+	}
+
+	if started {
+		return errors.New("Restore must be called before Start")
+	}
+
+	records, err := store.Load()
+	if err != nil {
+		return err
+	}
+
+	var maxID TaskID
+	var failed []string
+	restored := make([]*taskEntry, 0, len(records))
+	for _, rec := range records {
+		if rec.ID > maxID {
+			maxID = rec.ID
+		}
+
+		t, err := newTask(rec.Type, rec.Data)
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("task %d: %v", rec.ID, err))
+			continue
+		}
+
+		restored = append(restored, &taskEntry{task: t, at: rec.At, id: rec.ID})
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.started {
+		return errors.New("Restore must be called before Start")
+	}
+
+	// 恢复的写入排在之前加入的任务的写入之前，之前加入的任务与原记录ID相同时，原记录已被删除
+	pending := s.writes
+	s.writes = nil
+
+	if s.nextID < maxID {
+		s.nextID = maxID
+	}
+
+	for _, e := range restored {
+		old := e.id
+
+		s.nextID++
+		e.id = s.nextID
+		s.entries[e.id] = e
+		s.push(e)
+		s.save(e)
+		s.writes = append(s.writes, storeWrite{task: e.task, id: old})
+	}
+
+	s.writes = append(s.writes, pending...)
+
+	if len(failed) > 0 {
+		return fmt.Errorf("%d task(s) not restored: %s", len(failed), strings.Join(failed, "; "))
+	}
+
+	return nil
+}
+
+// storeWrite 待写入存储的操作，rec为nil时删除id对应的任务
+type storeWrite struct {
+	task ITimingTask
+	id   TaskID
+	rec  *TaskRecord
+}
+
+// save 将任务的写入操作加入队列，调用方需要持有锁；序列化或写入失败时调用任务的 OnError，任务仍然会执行
+func (s *TimingSchedule) save(e *taskEntry) {
+	p, ok := e.task.(IPersistentTask)
+	if s.store == nil || !ok {
+		return
+	}
+
+	data, err := p.MarshalTask()
+	if err != nil {
+		go s.onError(e.task, fmt.Errorf("save task %d: %w", e.id, err))
 		return
 	}
 
+	s.enqueue(storeWrite{task: e.task, id: e.id, rec: &TaskRecord{ID: e.id, Type: p.TaskType(), At: e.at, Data: data}})
+}
+
+// remove 将任务的删除操作加入队列，调用方需要持有锁
+func (s *TimingSchedule) remove(e *taskEntry) {
+	if _, ok := e.task.(IPersistentTask); s.store == nil || !ok {
+		return
+	}
+
+	s.enqueue(storeWrite{task: e.task, id: e.id})
+}
+
+func (s *TimingSchedule) enqueue(w storeWrite) {
+	s.writes = append(s.writes, w)
+
+	select {
+	case s.written <- struct{}{}:
+	default:
+	}
+}
+
+// write 按顺序将队列中的操作写入存储，存储的延迟不会阻塞调度器
+func (s *TimingSchedule) write() {
+	defer s.wg.Done()
+
+	for {
+		select {
+		case <-s.shutdown:
+			return
+
+		case <-s.written:
+			s.flush()
+		}
+	}
+}
+
+// flush 写入队列中的所有操作，同一时刻只能在一个协程中调用
+func (s *TimingSchedule) flush() {
+	for {
+		s.mutex.Lock()
+		writes := s.writes
+		s.writes = nil
+		s.mutex.Unlock()
+
+		if len(writes) == 0 {
+			return
+		}
+
+		for _, w := range writes {
+			if w.rec == nil {
+				if err := s.store.Delete(w.id); err != nil {
+					s.onError(w.task, fmt.Errorf("delete task %d: %w", w.id, err))
+				}
+				continue
+			}
+
+			if err := s.store.Save(w.rec); err != nil {
+				s.onError(w.task, fmt.Errorf("save task %d: %w", w.id, err))
+			}
+		}
+	}
+}
+
+// onError 调用任务的 OnError，为了防止调用task.OnError()发生panic，此处做了异常保护
+func (s *TimingSchedule) onError(t ITimingTask, err error) {
+	defer func() {
+		recover()
+	}()
+
+	t.OnError(err)
+}
+
+// Cancel 取消任务，返回是否取消成功；已开始执行的一次性任务无法取消，执行中的周期任务在本次执行后不再加入调度器
+func (s *TimingSchedule) Cancel(id TaskID) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	e, ok := s.entries[id]
+	if !ok {
+		return false
+	}
+
+	delete(s.entries, id)
+	e.cancelled = true
+	if e.index >= 0 {
+		heap.Remove(&s.tasks, e.index)
+	}
+	s.remove(e)
+
+	return true
+}
+
+// Reschedule 调整尚未执行的任务的执行时间，返回是否调整成功；任务正在执行时返回false。
+// 对周期任务只影响本次执行时间，之后的执行时间仍由 Next 计算
+func (s *TimingSchedule) Reschedule(id TaskID, at time.Time) bool {
+	s.mutex.Lock()
+	e, ok := s.entries[id]
+	if !ok || e.index < 0 {
+		s.mutex.Unlock()
+		return false
+	}
+
+	e.at = at.UnixNano()
+	heap.Fix(&s.tasks, e.index)
+	earliest := e.index == 0
+	s.save(e)
+	s.mutex.Unlock()
+
+	if earliest {
+		s.notify()
+	}
+
+	return true
+}
+
+// Get 查询任务及其执行时间，任务已执行或已取消时ok为false；执行中的周期任务返回本次的执行时间
+func (s *TimingSchedule) Get(id TaskID) (task ITimingTask, at time.Time, ok bool) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
-	heap.Push(&s.tasks, t)
+
+	e, ok := s.entries[id]
+	if !ok {
+		return nil, time.Time{}, false
+	}
+
+	return e.task, time.Unix(0, e.at), true
+}
+
+// notify 唤醒调度协程重新计算等待时间
+func (s *TimingSchedule) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
 }
 
+// pop 取出已到期的任务，没有到期的任务时返回nil
 func (s *TimingSchedule) pop() ITimingTask {
+	if e := s.popEntry(); e != nil {
+		return e.task
+	}
+
+	return nil
+}
+
+func (s *TimingSchedule) popEntry() *taskEntry {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
-	now := time.Now().Unix()
-	if s.tasks.Len() > 0 && now > s.tasks[0].RunAt() {
-		return heap.Pop(&s.tasks).(ITimingTask)
+	if s.tasks.Len() == 0 || time.Now().UnixNano() < s.tasks[0].at {
+		return nil
 	}
 
-	return nil
+	e := heap.Pop(&s.tasks).(*taskEntry)
+	if _, ok := e.task.(IRecurringTask); !ok {
+		delete(s.entries, e.id)
+	}
+
+	return e
+}
+
+// wait 距离最早的任务到期的时间，不超过检查间隔
+func (s *TimingSchedule) wait() time.Duration {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	d := time.Duration(s.intervalS) * time.Second
+	if d <= 0 {
+		d = time.Second
+	}
+
+	if s.tasks.Len() > 0 {
+		if due := time.Duration(s.tasks[0].at - time.Now().UnixNano()); due < d {
+			d = due
+		}
+	}
+
+	return d
 }
 
-// Start 启动调度器
+// Start 启动调度器，存储中尚未执行的任务需要在此之前通过 Restore 恢复
 func (s *TimingSchedule) Start() {
-	s.wg.Add(s.workerCount)
+	s.mutex.Lock()
+	s.started = true
+	s.mutex.Unlock()
+
+	s.wg.Add(s.workerCount + 2)
+
+	go s.dispatch()
+	go s.write()
 
 	for i := 0; i < s.workerCount; i++ {
 		go func() {
-			timer := time.NewTicker(time.Duration(s.intervalS) * time.Second)
-			defer func() {
-				timer.Stop()
-				s.wg.Done()
-			}()
+			defer s.wg.Done()
 
 			for {
 				select {
 				case <-s.shutdown:
 					return
 
-				case <-timer.C:
-					if curTask := s.pop(); curTask != nil {
-						// 为了防止调用task.OnError()发生panic，此处做了异常保护
-						onError := func(r interface{}) {
-							defer func() {
-								recover()
-							}()
-
-							curTask.OnError(fmt.Errorf("%v", r))
-						}
-
-						f := func() {
-							defer func() {
-								if r := recover(); r != nil {
-									onError(r)
-								}
-							}()
-
-							curTask.Run(s)
-						}
-
-						f()
-					}
+				case e := <-s.ready:
+					s.run(e)
 				}
 			}
 		}()
 	}
 }
 
-// Shutdown 停止调度器
+// dispatch 在最早的任务到期时唤醒，将到期的任务交给工作协程执行
+func (s *TimingSchedule) dispatch() {
+	defer s.wg.Done()
+
+	for {
+		timer := time.NewTimer(s.wait())
+
+		select {
+		case <-s.shutdown:
+			timer.Stop()
+			return
+
+		case <-s.wake:
+			timer.Stop()
+
+		case <-timer.C:
+		}
+
+		for e := s.popEntry(); e != nil; e = s.popEntry() {
+			select {
+			case <-s.shutdown:
+				return
+
+			case s.ready <- e:
+			}
+		}
+	}
+}
+
+// run 执行任务，周期任务执行后重新加入调度器，任务ID不变
+func (s *TimingSchedule) run(e *taskEntry) {
+	curTask := e.task
+	policy, misfired := s.misfire(e)
+
+	if (!misfired || (policy != MisfireSkip && policy != MisfireDrop)) && s.lock(e) && s.exec(e) {
+		// 因调度器关闭而中断的任务保留在存储中，下次启动时恢复
+		return
+	}
+
+	recurring, ok := curTask.(IRecurringTask)
+	if !ok {
+		s.mutex.Lock()
+		s.remove(e)
+		s.mutex.Unlock()
+		return
+	}
+
+	// 补执行错过的每一次时，从本次的计划时间计算下一次执行时间
+	from := time.Now()
+	if misfired && policy == MisfireRunAll {
+		from = time.Unix(0, e.at)
+	}
+
+	next := func() (more bool) {
+		defer func() {
+			if r := recover(); r != nil {
+				s.onError(curTask, fmt.Errorf("%v", r))
+				more = false
+			}
+		}()
+
+		return recurring.Next(from)
+	}
+
+	more := !(misfired && policy == MisfireDrop) && next()
+
+	s.mutex.Lock()
+	if e.cancelled {
+		s.mutex.Unlock()
+		return
+	}
+
+	if !more {
+		delete(s.entries, e.id)
+		s.remove(e)
+		s.mutex.Unlock()
+		return
+	}
+
+	e.at = runAtNano(curTask)
+	s.save(e)
+
+	// 调度器已关闭，存储中保留下一次执行时间，下次启动时恢复
+	if s.IsShutdown() {
+		delete(s.entries, e.id)
+		s.mutex.Unlock()
+		return
+	}
+
+	earliest := s.push(e)
+	s.mutex.Unlock()
+
+	if earliest {
+		s.notify()
+	}
+}
+
+// exec 以 IContextTask 的方式执行任务，返回任务是否因调度器关闭而中断；
+// 执行出错、超时或panic时调用任务的 OnError
+func (s *TimingSchedule) exec(e *taskEntry) (interrupted bool) {
+	curTask := e.task
+
+	var ctx context.Context
+	var cancel context.CancelFunc
+	timeout := taskTimeout(curTask)
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(s.ctx, timeout)
+	} else {
+		ctx, cancel = context.WithCancel(s.ctx)
+	}
+	defer cancel()
+
+	defer func() {
+		if r := recover(); r != nil {
+			s.onError(curTask, fmt.Errorf("%v", r))
+		}
+	}()
+
+	err := AsContextTask(curTask).RunContext(ctx, s)
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		s.onError(curTask, fmt.Errorf("task %d timed out after %s: %w", e.id, timeout, context.DeadlineExceeded))
+
+	case errors.Is(err, context.Canceled) && s.ctx.Err() != nil:
+		return true
+
+	case err != nil:
+		s.onError(curTask, err)
+	}
+
+	return false
+}
+
+// Shutdown 停止调度器，取消执行中的任务的ctx，并等待任务返回及存储写入完成
 func (s *TimingSchedule) Shutdown() {
 	close(s.shutdown)
+	s.cancel()
 	s.wg.Wait()
 
+	// 未启动时没有恢复存储中的任务，队列中的ID可能与存储中的冲突，不写入
+	s.mutex.Lock()
+	flush := s.store != nil && s.started
+	s.mutex.Unlock()
+
+	if flush {
+		s.flush()
+	}
+
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 	s.tasks = nil
+	s.entries = make(map[TaskID]*taskEntry)
 }
 
+// NewTimingSchedule 创建调度器，workerCount为工作协程数，intervalS为没有任务到期时的最长等待时间(秒)
 func NewTimingSchedule(workerCount int, intervalS int, tasks ...ITimingTask) *TimingSchedule {
 	s := &TimingSchedule{
 		shutdown:    make(chan struct{}),
+		wake:        make(chan struct{}, 1),
+		written:     make(chan struct{}, 1),
+		ready:       make(chan *taskEntry),
 		workerCount: workerCount,
 		intervalS:   intervalS,
+		entries:     make(map[TaskID]*taskEntry),
 	}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
 
-	if len(tasks) > 0 {
-		s.tasks = tasks
-		heap.Init(&s.tasks)
+	for i, t := range tasks {
+		s.nextID++
+		e := &taskEntry{task: t, at: runAtNano(t), id: s.nextID, index: i}
+		s.tasks = append(s.tasks, e)
+		s.entries[e.id] = e
 	}
+	heap.Init(&s.tasks)
 
 	return s
 }
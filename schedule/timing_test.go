@@ -5,6 +5,7 @@ import (
 	"math/rand"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	. "github.com/onsi/gomega"
@@ -48,6 +49,27 @@ func (r *ErrTask) OnError(err error) {
 	panic("panic on error")
 }
 
+type PreciseTask struct {
+	at    int64
+	fired chan time.Time
+}
+
+func (r *PreciseTask) RunAt() int64 {
+	return r.at / int64(time.Second)
+}
+
+func (r *PreciseTask) RunAtNano() int64 {
+	return r.at
+}
+
+func (r *PreciseTask) Run(s *TimingSchedule) {
+	r.fired <- time.Now()
+}
+
+func (r *PreciseTask) OnError(err error) {
+
+}
+
 func NewDemoTask(time string) ITimingTask {
 	t, _ := NewTodayTime(time)
 	return &DemoTask{timestamp: t}
@@ -188,6 +210,80 @@ var _ = Describe("Timing", func() {
 			s.Push(NewDemoTask(GetNextSecondTime()))
 			Expect(s.Len() == 0).Should(BeTrue())
 		})
+
+		It("sub-second precision", func() {
+			s := NewTimingSchedule(2, 10)
+			s.Start()
+			defer s.Shutdown()
+
+			fired := make(chan time.Time, 2)
+			late := &PreciseTask{at: time.Now().Add(800 * time.Millisecond).UnixNano(), fired: fired}
+			s.Push(late)
+
+			// 调度器已按 late 设置唤醒时间，Push 更早的任务需要重新计算
+			early := &PreciseTask{at: time.Now().Add(200 * time.Millisecond).UnixNano(), fired: fired}
+			s.Push(early)
+
+			for _, t := range []*PreciseTask{early, late} {
+				at := <-fired
+				delay := at.Sub(time.Unix(0, t.at))
+				Expect(delay >= 0 && delay < 100*time.Millisecond).Should(BeTrue())
+			}
+		})
+
+		It("cancel and reschedule", func() {
+			s := NewTimingSchedule(2, 10)
+			s.Start()
+			defer s.Shutdown()
+
+			fired := make(chan time.Time, 3)
+			cancelled := &PreciseTask{at: time.Now().Add(300 * time.Millisecond).UnixNano(), fired: fired}
+			moved := &PreciseTask{at: time.Now().Add(time.Hour).UnixNano(), fired: fired}
+
+			id1 := s.Push(cancelled)
+			id2 := s.Push(moved)
+			Expect(id1 != 0 && id1 != id2).Should(BeTrue())
+
+			task, at, ok := s.Get(id2)
+			Expect(ok && task == moved && at.UnixNano() == moved.at).Should(BeTrue())
+
+			Expect(s.Cancel(id1)).Should(BeTrue())
+			Expect(s.Cancel(id1)).Should(BeFalse())
+			Expect(s.Len() == 1).Should(BeTrue())
+
+			runAt := time.Now().Add(200 * time.Millisecond)
+			Expect(s.Reschedule(id2, runAt)).Should(BeTrue())
+
+			at = <-fired
+			delay := at.Sub(runAt)
+			Expect(delay >= 0 && delay < 100*time.Millisecond).Should(BeTrue())
+
+			time.Sleep(200 * time.Millisecond)
+			Expect(len(fired) == 0).Should(BeTrue())
+
+			_, _, ok = s.Get(id2)
+			Expect(ok).Should(BeFalse())
+			Expect(s.Reschedule(id2, time.Now())).Should(BeFalse())
+		})
+
+		It("cancel recurring task", func() {
+			s := NewTimingSchedule(2, 10)
+			s.Start()
+			defer s.Shutdown()
+
+			counter := &CountTask{}
+			id := s.Push(NewIntervalTask(counter, 50))
+
+			time.Sleep(180 * time.Millisecond)
+			_, _, ok := s.Get(id)
+			Expect(ok).Should(BeTrue())
+			Expect(s.Cancel(id)).Should(BeTrue())
+
+			time.Sleep(100 * time.Millisecond)
+			runs := atomic.LoadInt32(&counter.count)
+			time.Sleep(150 * time.Millisecond)
+			Expect(atomic.LoadInt32(&counter.count) == runs && s.Len() == 0).Should(BeTrue())
+		})
 	})
 
 })